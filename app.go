@@ -8,6 +8,7 @@ import (
 	"os/signal"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
@@ -15,11 +16,14 @@ import (
 
 	"github.com/spf13/pflag"
 
+	"github.com/kkkbird/qapp/qclock"
+	"github.com/kkkbird/qapp/qcontext"
 	"github.com/kkkbird/qapp/qdebugserver"
+	"github.com/kkkbird/qapp/qpanic"
 	"github.com/sirupsen/logrus"
 )
 
-var log = logrus.WithField("pkg", "qapp")
+var log = logrus.WithFields(logrus.Fields{"pkg": "qapp", "instance": instanceID, "host": hostname})
 
 func getFuncName(f interface{}) string {
 	fv := reflect.ValueOf(f)
@@ -61,8 +65,10 @@ func (s *InitStage) Run(ctx context.Context, a *Application) error {
 
 			defer func() {
 				if r := recover(); r != nil {
-					log.Errorf("qapp init catch panic: %s\n%s\n", r, stack(6))
-					a.initErrChan <- fmt.Errorf("%s() panic:%s", funcName, r)
+					st := stack(6)
+					qpanic.Add(r, st, "init "+funcName)
+					log.Errorf("qapp init catch panic: %s\n%s\n", r, st)
+					a.initErrChan <- &panicError{fmt.Sprintf("%s() panic:%s", funcName, r), st}
 				}
 			}()
 
@@ -111,7 +117,9 @@ func (s *InitStage) Clean(ctx context.Context, a *Application) error {
 
 			defer func() {
 				if r := recover(); r != nil {
-					log.Errorf("qapp clean catch panic: %s\n%s\n", r, stack(6))
+					st := stack(6)
+					qpanic.Add(r, st, "clean "+funcName)
+					log.Errorf("qapp clean catch panic: %s\n%s\n", r, st)
 				}
 			}()
 
@@ -139,12 +147,23 @@ func newInitStage(name string, funcs []InitFunc) *InitStage {
 type Application struct {
 	initTimeout             time.Duration
 	initErrChan             chan error
+	shutdownChan            chan error
+	clock                   qclock.Clock
 	cleanTimeout            time.Duration // default 1s
 	daemonForceCloseTimeout time.Duration // default 1s
 
 	preload             func() error
 	envPrefix           string
+	dotEnvFiles         []string
+	secretKeys          []string
+	decryptedKeys       sync.Map
+	strict              bool
+	crashDir            string
+	crashKeep           int
 	onConfigFileChanged func()
+	configFileLoaded    bool
+	configMu            sync.Mutex // serialize config reloads
+	remoteConfig        *RemoteConfig
 	cmdline             *pflag.FlagSet
 	name                string
 	initedStageIdx      int
@@ -152,8 +171,18 @@ type Application struct {
 	initConditions      []func() bool
 	daemons             []DaemonFunc
 	daemonConditions    []func() bool
+	daemonTierIdx       []int
+	tierBudgets         map[int]time.Duration
 }
 
+// predefined shutdown tiers, on shutdown daemons in lower tier are canceled first,
+// and the next tier is canceled only after all daemons of the previous tier exit or its budget is used up
+const (
+	TierIngress = 0  // servers accept requests, it is the default tier
+	TierWorker  = 10 // workers draining queues
+	TierStore   = 20 // producers and stores the workers write to
+)
+
 // AppOpts is setters for application options
 type AppOpts func(a *Application)
 
@@ -184,6 +213,22 @@ func WithDaemonForceCloseTimeout(timeout time.Duration) AppOpts {
 	}
 }
 
+// WithShutdownTier set force close budget of a shutdown tier, default is the daemon force close timeout,
+// budget 0 means the next tier is canceled without waiting, and negative means waiting the tier forever
+func WithShutdownTier(tier int, budget time.Duration) AppOpts {
+	return func(a *Application) {
+		a.tierBudgets[tier] = budget
+	}
+}
+
+// WithClock set the clock of init, clean and daemon timeouts, ex a qclock.Fake in tests,
+// the clock is also attached to the contexts passed to init, clean and daemon funcs
+func WithClock(c qclock.Clock) AppOpts {
+	return func(a *Application) {
+		a.clock = c
+	}
+}
+
 // WithLogger set logger of application
 // func WithLogger(logger Logger) AppOpts {
 // 	return func(a *Application) {
@@ -201,8 +246,11 @@ func New(name string, opts ...AppOpts) *Application {
 		name:                    name,
 		initStages:              make([]*InitStage, 0),
 		daemons:                 make([]DaemonFunc, 0),
+		tierBudgets:             make(map[int]time.Duration),
+		clock:                   qclock.Real,
 
-		initErrChan: make(chan error, 1),
+		initErrChan:  make(chan error, 1),
+		shutdownChan: make(chan error, 1),
 	}
 
 	for _, opt := range opts {
@@ -211,18 +259,22 @@ func New(name string, opts ...AppOpts) *Application {
 
 	app.AddInitStage("preload", app.initParams).AddDaemons(qdebugserver.Run)
 
+	if app.remoteConfig != nil {
+		app.AddDaemons(app.pollRemoteConfig)
+	}
+
 	return app
 }
 
 func (a *Application) initParams(ctx context.Context) (CleanFunc, error) {
 	var err error
 
-	if err = a.handleFlagsAndEnv(); err != nil {
+	if err = a.handleFlagsAndEnv(ctx); err != nil {
 		return nil, err
 	}
 
 	buf := bytes.NewBufferString("")
-	showAppVersion(buf, a.name)
+	showAppVersion(buf, a.name, "")
 	for _, s := range strings.Split(buf.String(), "\n") {
 		log.Debug(s)
 	}
@@ -256,10 +308,20 @@ func (a *Application) AddDaemons(funcs ...DaemonFunc) *Application {
 }
 
 func (a *Application) AddDaemonsWithCondition(condition func() bool, funcs ...DaemonFunc) *Application {
+	return a.addDaemons(condition, TierIngress, funcs...)
+}
+
+// AddDaemonsWithTier add daemons to a shutdown tier, see TierIngress
+func (a *Application) AddDaemonsWithTier(tier int, funcs ...DaemonFunc) *Application {
+	return a.addDaemons(nil, tier, funcs...)
+}
+
+func (a *Application) addDaemons(condition func() bool, tier int, funcs ...DaemonFunc) *Application {
 	a.daemons = append(a.daemons, funcs...)
 
 	for range funcs {
 		a.daemonConditions = append(a.daemonConditions, condition)
+		a.daemonTierIdx = append(a.daemonTierIdx, tier)
 	}
 
 	return a
@@ -267,13 +329,13 @@ func (a *Application) AddDaemonsWithCondition(condition func() bool, funcs ...Da
 
 func (a *Application) runInitStages() error {
 	var (
-		ctx    = context.Background()
+		ctx    = qclock.WithClock(context.Background(), a.clock)
 		cancel context.CancelFunc
 		err    error
 	)
 
 	if a.initTimeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, a.initTimeout)
+		ctx, cancel = qclock.WithTimeout(ctx, a.initTimeout)
 	} else {
 		ctx, cancel = context.WithCancel(ctx)
 	}
@@ -302,12 +364,12 @@ func (a *Application) runInitStages() error {
 			}
 		case err = <-a.initErrChan:
 			cancel()
-			if !strings.HasSuffix(err.Error(), ErrShowVersion.Error()) {
+			if !isExitRequest(err) {
 				log.WithError(err).Errorf("!!Init err, exit in 1s")
 			}
 			select { // wait the init stage done or cleanTimeout duration
 			case <-cErr:
-			case <-time.After(time.Second):
+			case <-a.clock.After(time.Second):
 			}
 
 			return err
@@ -315,7 +377,7 @@ func (a *Application) runInitStages() error {
 			log.Errorf("!!Init timeount, exit in 1s")
 			select { // wait the init stage done or cleanTimeout duration
 			case <-cErr:
-			case <-time.After(time.Second):
+			case <-a.clock.After(time.Second):
 			}
 
 			return ctx.Err()
@@ -327,12 +389,12 @@ func (a *Application) runInitStages() error {
 
 func (a *Application) runCleanStage() {
 	var (
-		ctx    = context.Background()
+		ctx    = qclock.WithClock(context.Background(), a.clock)
 		cancel context.CancelFunc
 	)
 
 	if a.cleanTimeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, a.cleanTimeout)
+		ctx, cancel = qclock.WithTimeout(ctx, a.cleanTimeout)
 	} else {
 		ctx, cancel = context.WithCancel(ctx)
 	}
@@ -364,49 +426,147 @@ func (a *Application) runCleanStage() {
 	}
 }
 
+// daemonTier is daemons shutdown together
+type daemonTier struct {
+	tier    int
+	budget  time.Duration
+	daemons []DaemonFunc
+	ctx     context.Context
+	done    chan struct{}
+}
+
+// daemonTiers return tiers of enabled daemons in shutdown order
+func (a *Application) daemonTiers() []*daemonTier {
+	tiers := make(map[int]*daemonTier)
+
+	for idx, d := range a.daemons {
+		condi := a.daemonConditions[idx]
+		if condi != nil && !condi() {
+			continue
+		}
+
+		tier := a.daemonTierIdx[idx]
+		t, ok := tiers[tier]
+		if !ok {
+			budget, ok := a.tierBudgets[tier]
+			if !ok {
+				budget = a.daemonForceCloseTimeout
+			}
+			t = &daemonTier{tier: tier, budget: budget, done: make(chan struct{})}
+			tiers[tier] = t
+		}
+		t.daemons = append(t.daemons, d)
+	}
+
+	sorted := make([]*daemonTier, 0, len(tiers))
+	for _, t := range tiers {
+		sorted = append(sorted, t)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].tier < sorted[j].tier })
+
+	return sorted
+}
+
+// nextTierContext return the context of the tier after a tier with budget and done, it is canceled after done is closed
+// or budget is used up since the previous tier is canceled. budget 0 means no delay, and negative means waiting done forever
+func nextTierContext(prev context.Context, budget time.Duration, done <-chan struct{}) (context.Context, context.CancelFunc) {
+	switch {
+	case budget == 0:
+		return context.WithCancel(prev)
+	case budget < 0:
+		return qcontext.WithDelayedContextUntil(prev, 0, done)
+	}
+	return qcontext.WithDelayedContextUntil(prev, budget, done)
+}
+
 func (a *Application) runDaemons() error {
 
 	var (
 		ctx    context.Context
-		cancel context.CancelFunc
+		cancel context.CancelCauseFunc
 		cErr   = make(chan error, len(a.daemons))
 		cDone  = make(chan interface{}, 1)
+		tiers  = a.daemonTiers()
 	)
 
-	ctx, cancel = context.WithCancel(context.Background())
-	defer cancel()
+	ctx, cancel = context.WithCancelCause(qclock.WithClock(context.Background(), a.clock))
+	defer cancel(nil)
+
+	// tier contexts are chained, a tier is canceled after the previous tier exits or its budget is used up
+	var (
+		prevCtx  context.Context = ctx
+		prevDone                 = make(chan struct{})
+	)
+	close(prevDone)
+
+	for i, t := range tiers {
+		var tierCancel context.CancelFunc
+		if i == 0 {
+			t.ctx, tierCancel = context.WithCancel(ctx)
+		} else {
+			t.ctx, tierCancel = nextTierContext(prevCtx, tiers[i-1].budget, prevDone)
+		}
+		defer tierCancel()
+
+		stop := context.AfterFunc(t.ctx, func() {
+			log.Infof("!!Stopping tier %d daemons, force close in %s ...", t.tier, t.budget)
+		})
+		defer stop()
+
+		prevCtx, prevDone = t.ctx, t.done
+	}
+
+	// force close after the last tier exits or its budget is used up
+	forceCloseCtx, forceCloseCancel := context.WithCancel(ctx)
+	if len(tiers) > 0 {
+		forceCloseCtx, forceCloseCancel = nextTierContext(prevCtx, tiers[len(tiers)-1].budget, prevDone)
+	}
+	defer forceCloseCancel()
 
 	// run daemon funcs
 	go func() {
 		var wg sync.WaitGroup
 
-		for idx, d := range a.daemons {
-			condi := a.daemonConditions[idx]
-			if condi != nil && !condi() {
-				continue
-			}
+		for _, t := range tiers {
+			var tierWg sync.WaitGroup
+
+			for _, d := range t.daemons {
+				wg.Add(1)
+				tierWg.Add(1)
 
-			wg.Add(1)
+				go func(_d DaemonFunc, _ctx context.Context) {
+					defer wg.Done()
+					defer tierWg.Done()
 
-			go func(_d DaemonFunc) {
-				defer wg.Done()
+					funcName := getFuncName(_d)
 
-				funcName := getFuncName(_d)
+					defer func() {
+						if r := recover(); r != nil {
+							st := stack(6)
+							qpanic.Add(r, st, "daemon "+funcName)
+							log.Errorf("qapp daemon catch panic: %s\n%s\n", r, st)
+							cErr <- &qcontext.DaemonFailedCause{Name: funcName, Err: &panicError{fmt.Sprintf("panic:%s", r), st}}
+						}
+					}()
 
-				defer func() {
-					if r := recover(); r != nil {
-						log.Errorf("qapp daemon catch panic: %s\n%s\n", r, stack(6))
-						cErr <- fmt.Errorf("%s() panic:%s", funcName, r)
+					log.Tracef("  %s() ... running", funcName)
+					if err := _d(_ctx); err != nil {
+						cErr <- &qcontext.DaemonFailedCause{Name: funcName, Err: err}
+						return
 					}
-				}()
+					if _ctx.Err() != nil {
+						log.Tracef("  %s() ... done, %s", funcName, qcontext.CauseChain(_ctx))
+					} else {
+						log.Tracef("  %s() ... done", funcName)
+					}
+				}(d, t.ctx)
+			}
 
-				log.Tracef("  %s() ... running", funcName)
-				if err := _d(ctx); err != nil {
-					cErr <- fmt.Errorf("%s():%s", funcName, err)
-					return
-				}
-				log.Tracef("  %s() ... done", funcName)
-			}(d)
+			go func(_t *daemonTier) {
+				tierWg.Wait()
+				log.Tracef("  tier %d daemons done", _t.tier)
+				close(_t.done)
+			}(t)
 		}
 
 		wg.Wait()
@@ -422,31 +582,38 @@ func (a *Application) runDaemons() error {
 
 __daemon_loop:
 	for {
-		var closeTimer <-chan time.Time
-		var daemonErrChan <-chan error
+		var closeTimer <-chan struct{}
+		var daemonErrChan, shutdownChan <-chan error
 		if isCanceled {
-			closeTimer = time.After(a.daemonForceCloseTimeout)
+			closeTimer = forceCloseCtx.Done()
 			daemonErrChan = nil
+			shutdownChan = nil
 		} else {
 			closeTimer = nil
 			daemonErrChan = cErr
+			shutdownChan = a.shutdownChan
 		}
 
 		select {
 		case err = <-daemonErrChan:
-			log.WithError(err).Errorf("!!Daemon err, exit in %s ...", a.daemonForceCloseTimeout.String())
-			cancel()
+			log.WithError(err).Error("!!Daemon err, exit ...")
+			cancel(err)
+			isCanceled = true
+			cSignal = nil // set cSignal to nil to ignore multi signal
+		case cause := <-shutdownChan:
+			log.Infof("!!Shutdown by %s, exit ...", cause)
+			cancel(cause)
 			isCanceled = true
 			cSignal = nil // set cSignal to nil to ignore multi signal
 		case <-closeTimer:
-			log.Infof("!!Daemon exit after %s", a.daemonForceCloseTimeout.String())
+			log.Infof("!!Daemon exit, %s", qcontext.CauseChain(forceCloseCtx))
 			break __daemon_loop
 		case <-cDone:
 			log.Trace("  all daemons done")
 			break __daemon_loop
 		case s := <-cSignal:
-			log.Infof("!!Received signal:%s, exit in %s ...", s, a.daemonForceCloseTimeout.String())
-			cancel()
+			log.Infof("!!Received signal:%s, exit ...", s)
+			cancel(&qcontext.SignalCause{Signal: s})
 			isCanceled = true
 			cSignal = nil // set cSignal to nil to ignore multi signal
 		}
@@ -454,22 +621,49 @@ __daemon_loop:
 	return err
 }
 
-// Run run qapp app, it should be called at last
+// Shutdown cancel all daemons with cause, ex &qcontext.ReloadCause{} or &qcontext.AdminCause{},
+// daemons could get it by context.Cause(ctx). only the first call takes effect
+func (a *Application) Shutdown(cause error) {
+	if cause == nil {
+		cause = &qcontext.AdminCause{Reason: "shutdown"}
+	}
+
+	select {
+	case a.shutdownChan <- cause:
+	default:
+	}
+}
+
+// Run run qapp app, it should be called at last. the process exits with non-zero code after clean stages
+// if init or daemons fail, or an init func requests so, ex ErrVersionMismatch
 func (a *Application) Run() {
-	var err error
+	if code := exitCode(a.run()); code != 0 {
+		os.Exit(code)
+	}
+}
+
+func (a *Application) run() (err error) {
+	if isShortCircuit(os.Args[1:]) { // stdout is kept for the output, ex --version -o json
+		logToStderr()
+	}
 
 	log.Infof("Application [%s] starting...", a.name)
 
 	defer a.runCleanStage()
 
 	if err = a.runInitStages(); err != nil {
+		if !isExitRequest(err) {
+			a.writeCrashReport(err)
+		}
 		return //log.WithError(err).Panic("Application fail to init!")
 	}
 
 	log.Infof("All init stage done, starting daemons...")
 
 	if err = a.runDaemons(); err != nil {
+		a.writeCrashReport(err)
 		return //log.WithError(err).Panic("Application fail to run daemon!")
 	}
 	log.Infof("Application [%s] done", a.name)
+	return nil
 }
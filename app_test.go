@@ -0,0 +1,197 @@
+package qapp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kkkbird/qapp/qclock"
+	"github.com/kkkbird/qapp/qcontext"
+)
+
+// newTestApp return an app without the default debug server daemon
+func newTestApp(opts ...AppOpts) *Application {
+	a := New("test", opts...)
+	a.daemons, a.daemonConditions, a.daemonTierIdx = nil, nil, nil
+	return a
+}
+
+// runDaemonsAsync run daemons of a and return the chan of its result
+func runDaemonsAsync(a *Application) <-chan error {
+	cErr := make(chan error, 1)
+	go func() {
+		cErr <- a.runDaemons()
+	}()
+	return cErr
+}
+
+func TestRunDaemonsTiers(t *testing.T) {
+	clock := qclock.NewFake(time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC))
+
+	var (
+		mu      sync.Mutex
+		stopped []string
+	)
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = append(stopped, name)
+	}
+
+	var (
+		workerCanceled = make(chan struct{})
+		storeCause     = make(chan error, 1)
+		release        = make(chan struct{})
+	)
+	defer close(release)
+
+	a := newTestApp(WithClock(clock), WithShutdownTier(TierWorker, 2*time.Second)).
+		AddDaemonsWithTier(TierIngress, func(ctx context.Context) error {
+			<-ctx.Done()
+			record("ingress")
+			return nil
+		}).
+		AddDaemonsWithTier(TierWorker, func(ctx context.Context) error {
+			<-ctx.Done()
+			record("worker")
+			close(workerCanceled)
+			<-release // stuck until force closed
+			return nil
+		}).
+		AddDaemonsWithTier(TierStore, func(ctx context.Context) error {
+			<-ctx.Done()
+			record("store")
+			storeCause <- context.Cause(ctx)
+			return nil
+		})
+
+	cErr := runDaemonsAsync(a)
+	a.Shutdown(nil)
+
+	<-workerCanceled
+	clock.BlockUntil(1) // budget timer of the worker tier
+
+	select {
+	case <-storeCause:
+		t.Fatal("store tier should wait the worker tier")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(2 * time.Second)
+
+	cause := <-storeCause
+	if !errors.Is(cause, qcontext.ErrDelayedContextTimeout) {
+		t.Errorf("store tier cause = %v, expected %v", cause, qcontext.ErrDelayedContextTimeout)
+	}
+
+	if err := <-cErr; err != nil {
+		t.Errorf("runDaemons() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"ingress", "worker", "store"}
+	if len(stopped) != len(expected) {
+		t.Fatalf("stopped = %v, expected %v", stopped, expected)
+	}
+	for i := range expected {
+		if stopped[i] != expected[i] {
+			t.Fatalf("stopped = %v, expected %v", stopped, expected)
+		}
+	}
+}
+
+func TestRunDaemonsZeroForceCloseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	a := newTestApp(WithClock(qclock.NewFake(time.Now())), WithDaemonForceCloseTimeout(0)).
+		AddDaemons(func(ctx context.Context) error {
+			<-release // never exits by itself
+			return nil
+		})
+
+	cErr := runDaemonsAsync(a)
+	a.Shutdown(nil)
+
+	select {
+	case err := <-cErr:
+		if err != nil {
+			t.Errorf("runDaemons() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("runDaemons() should force close at once with timeout 0")
+	}
+}
+
+func TestRunInitStagesTimeout(t *testing.T) {
+	clock := qclock.NewFake(time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC))
+	started := make(chan struct{})
+
+	a := newTestApp(WithClock(clock), WithInitTimeout(5*time.Second)).
+		AddInitStage("slow", func(ctx context.Context) (CleanFunc, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, nil
+		})
+	a.initStages[0] = newInitStage("preload", nil) // skip flags and config
+
+	cErr := make(chan error, 1)
+	go func() {
+		cErr <- a.runInitStages()
+	}()
+
+	<-started
+	clock.Advance(4 * time.Second)
+
+	select {
+	case err := <-cErr:
+		t.Fatalf("runInitStages() should wait the init timeout, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	if err := <-cErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("runInitStages() error = %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRunCleanStageTimeout(t *testing.T) {
+	clock := qclock.NewFake(time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC))
+	release := make(chan struct{})
+	defer close(release)
+
+	a := newTestApp(WithClock(clock), WithCleanTimeout(2*time.Second)).
+		AddInitStage("db", func(ctx context.Context) (CleanFunc, error) {
+			return func(ctx context.Context) {
+				<-release // stuck until clean timeout
+			}, nil
+		})
+	a.initStages[0] = newInitStage("preload", nil)
+
+	if err := a.runInitStages(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.runCleanStage()
+		close(done)
+	}()
+
+	clock.BlockUntil(1) // clean timeout timer
+	select {
+	case <-done:
+		t.Fatal("runCleanStage() should wait the clean func")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(2 * time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runCleanStage() should return at clean timeout")
+	}
+}
@@ -0,0 +1,62 @@
+// qappenc encrypt a value to "enc:" config value which is decrypted by qapp at load time
+//
+// usage:
+//
+//	qappenc -genkey > app.key
+//	qappenc -keyfile app.key "my password"
+//	echo -n "my password" | QAPP_CONFIG_KEY=$(cat app.key) qappenc -env QAPP_CONFIG_KEY
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kkkbird/qapp"
+)
+
+func main() {
+	var (
+		genKey  = flag.Bool("genkey", false, "generate a random key")
+		keyFile = flag.String("keyfile", "", "key file")
+		env     = flag.String("env", "QAPP_CONFIG_KEY", "env of the key, it is used before key file")
+		decrypt = flag.Bool("d", false, "decrypt value")
+	)
+	flag.Parse()
+
+	if *genKey {
+		key, err := qapp.GenerateConfigKey()
+		exitOnErr(err)
+		fmt.Println(key)
+		return
+	}
+
+	key, err := qapp.LoadConfigKey(*env, *keyFile)
+	exitOnErr(err)
+
+	var value string
+	if flag.NArg() > 0 {
+		value = flag.Arg(0)
+	} else {
+		data, err := io.ReadAll(os.Stdin)
+		exitOnErr(err)
+		value = string(data)
+	}
+
+	if *decrypt {
+		value, err = qapp.DecryptValue(key, value)
+	} else {
+		value, err = qapp.EncryptValue(key, value)
+	}
+	exitOnErr(err)
+
+	fmt.Println(value)
+}
+
+func exitOnErr(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,108 @@
+package qapp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+)
+
+// config sources, file and env sources are suffixed by the file or env name, ex "file:app.yml"
+const (
+	ConfigSourceDefault = "default"
+	ConfigSourceFile    = "file"
+	ConfigSourceEnv     = "env"
+	ConfigSourceFlag    = "flag"
+	ConfigSourceRemote  = "remote"
+)
+
+const secretMask = "******"
+
+// default patterns of secret keys, see WithSecretKeys
+var defaultSecretKeys = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "credential", "private", "dsn"}
+
+// ConfigEntry is an effective config key with its value and the source supplied it
+type ConfigEntry struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// configSource return where the effective value of key comes from,
+// the check order is same as viper's priority: flag > env > remote > file > default
+func (a *Application) configSource(key string) string {
+	if f := a.cmdline.Lookup(key); f != nil && f.Changed {
+		return ConfigSourceFlag + ":--" + f.Name
+	}
+
+	name := a.envName(key)
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		return ConfigSourceEnv + ":" + name
+	}
+
+	if a.remoteConfig != nil && a.remoteConfig.inConfig(key) {
+		return ConfigSourceRemote + ":" + a.remoteConfig.URL
+	}
+
+	if viper.InConfig(key) {
+		return ConfigSourceFile + ":" + viper.ConfigFileUsed()
+	}
+
+	return ConfigSourceDefault
+}
+
+// isSecretKey check if key is decrypted or any part of key matches the secret patterns
+func (a *Application) isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	if key == configKeyName {
+		return true
+	}
+	if _, ok := a.decryptedKeys.Load(key); ok {
+		return true
+	}
+
+	for _, patterns := range [][]string{defaultSecretKeys, a.secretKeys} {
+		for _, p := range patterns {
+			if strings.Contains(key, strings.ToLower(p)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// effectiveConfig return all keys known by viper, sorted by key, secret values are masked
+func (a *Application) effectiveConfig() []ConfigEntry {
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+
+	entries := make([]ConfigEntry, 0, len(keys))
+	for _, k := range keys {
+		v := viper.Get(k)
+		if a.isSecretKey(k) && v != nil && fmt.Sprint(v) != "" {
+			v = secretMask
+		}
+
+		entries = append(entries, ConfigEntry{
+			Key:    k,
+			Value:  v,
+			Source: a.configSource(k),
+		})
+	}
+	return entries
+}
+
+func printConfig(w io.Writer, entries []ConfigEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%v\t%s\n", e.Key, e.Value, e.Source)
+	}
+
+	return tw.Flush()
+}
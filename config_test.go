@@ -0,0 +1,99 @@
+package qapp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestIsSecretKey(t *testing.T) {
+	a := &Application{secretKeys: []string{"Session"}}
+	a.decryptedKeys.Store("app.plain", true)
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"db.main.password", true},
+		{"db.main.dsn", true},
+		{"redis.AUTH_TOKEN", true},
+		{"http.session_key", true},
+		{"app.plain", true},
+		{configKeyName, true},
+		{"db.main.max_open", false},
+		{"app.name", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := a.isSecretKey(tt.key); got != tt.want {
+				t.Errorf("isSecretKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	file := filepath.Join(t.TempDir(), "app.yml")
+	err := os.WriteFile(file, []byte("app:\n  name: from-file\n  port: 80\n  debug: false\ndb:\n  password: p@ss\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmdline := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	cmdline.Bool("app.debug", false, "debug")
+	if err = cmdline.Parse([]string{"--app.debug"}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Application{envPrefix: "qcfg", cmdline: cmdline}
+	t.Setenv("QCFG_APP_PORT", "8080")
+
+	viper.SetDefault("app.timeout", "1s")
+	viper.SetConfigFile(file)
+	if err = viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	viper.BindPFlags(cmdline)
+	viper.AutomaticEnv()
+	viper.SetEnvPrefix(a.envPrefix)
+	viper.SetEnvKeyReplacer(envKeyReplacer)
+
+	entries := a.effectiveConfig()
+
+	expected := map[string]ConfigEntry{
+		"app.name":    {Key: "app.name", Value: "from-file", Source: "file:" + file},
+		"app.port":    {Key: "app.port", Value: "8080", Source: "env:QCFG_APP_PORT"},
+		"app.debug":   {Key: "app.debug", Value: true, Source: "flag:--app.debug"},
+		"app.timeout": {Key: "app.timeout", Value: "1s", Source: ConfigSourceDefault},
+		"db.password": {Key: "db.password", Value: secretMask, Source: "file:" + file},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("effectiveConfig() = %+v", entries)
+	}
+	for i, e := range entries {
+		if i > 0 && entries[i-1].Key >= e.Key {
+			t.Errorf("effectiveConfig() should be sorted by key: %+v", entries)
+		}
+		if want := expected[e.Key]; e != want {
+			t.Errorf("effectiveConfig() entry = %+v, want %+v", e, want)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err = printConfig(buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "KEY") || !strings.Contains(out, secretMask) || strings.Contains(out, "p@ss") {
+		t.Errorf("printConfig() output:\n%s", out)
+	}
+}
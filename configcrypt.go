@@ -0,0 +1,176 @@
+package qapp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EncryptedPrefix is the prefix of encrypted config values
+const EncryptedPrefix = "enc:"
+
+// config key of the encryption key, the key itself is read from env ex "QAPP_CONFIG_KEY" or the key file
+const (
+	FlagConfigKeyFile = "config.keyfile"
+	configKeyName     = "config.key"
+)
+
+// Predefined errors
+var (
+	ErrNoConfigKey       = errors.New("no config key to decrypt config")
+	ErrInvalidCiphertext = errors.New("invalid encrypted config value")
+)
+
+// ParseConfigKey decode a base64 encoded AES key, the key must be 16, 24 or 32 bytes
+func ParseConfigKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("config key must be base64 encoded: %w", err)
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+	return nil, fmt.Errorf("invalid config key size %d", len(key))
+}
+
+// LoadConfigKey read config key from env, or key file if env is empty
+func LoadConfigKey(env, keyFile string) ([]byte, error) {
+	if s := os.Getenv(env); len(s) > 0 {
+		return ParseConfigKey(s)
+	}
+
+	if len(keyFile) > 0 {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return ParseConfigKey(string(data))
+	}
+
+	return nil, ErrNoConfigKey
+}
+
+// GenerateConfigKey return a random base64 encoded 32 bytes key
+func GenerateConfigKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// EncryptValue encrypt plaintext with AES-GCM, return "enc:" + base64(nonce + ciphertext)
+func EncryptValue(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptValue decrypt value encrypted by EncryptValue
+func DecryptValue(key []byte, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptConfig decrypt all "enc:" values, each value is replaced in the layer it comes from so that
+// a later reload of config file or remote config is not shadowed by the stale plaintext.
+// Values from env are replaced by viper.Set, env outranks all reloadable layers and is never reloaded
+func (a *Application) decryptConfig() error {
+	var (
+		key    []byte
+		err    error
+		merged = make(map[string]interface{})
+	)
+
+	for _, k := range viper.AllKeys() {
+		s, ok := viper.Get(k).(string)
+		if !ok || !strings.HasPrefix(s, EncryptedPrefix) {
+			continue
+		}
+
+		if key == nil {
+			if key, err = LoadConfigKey(a.envName(configKeyName), viper.GetString(FlagConfigKeyFile)); err != nil {
+				return err
+			}
+		}
+
+		plaintext, err := DecryptValue(key, s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+
+		a.decryptedKeys.Store(k, true)
+
+		switch src := a.configSource(k); {
+		case strings.HasPrefix(src, ConfigSourceFlag):
+			if err = a.cmdline.Set(k, plaintext); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+			continue
+		case strings.HasPrefix(src, ConfigSourceEnv):
+			viper.Set(k, plaintext)
+			continue
+		case src == ConfigSourceDefault:
+			viper.SetDefault(k, plaintext)
+			continue
+		}
+
+		// build nested map for MergeConfigMap
+		m := merged
+		path := strings.Split(k, ".")
+		for _, p := range path[:len(path)-1] {
+			sub, ok := m[p].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				m[p] = sub
+			}
+			m = sub
+		}
+		m[path[len(path)-1]] = plaintext
+	}
+
+	if len(merged) == 0 {
+		return nil
+	}
+	return viper.MergeConfigMap(merged)
+}
@@ -0,0 +1,114 @@
+package qapp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestEncryptValue(t *testing.T) {
+	s, err := GenerateConfigKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseConfigKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, _ := ParseConfigKey("MDEyMzQ1Njc4OWFiY2RlZg==")
+
+	enc, err := EncryptValue(key, "p@ss word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enc, EncryptedPrefix) {
+		t.Fatalf("missing prefix: %s", enc)
+	}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		value   string
+		want    string
+		wantErr error
+	}{
+		{"Round trip", key, enc, "p@ss word", nil},
+		{"Wrong key", otherKey, enc, "", ErrInvalidCiphertext},
+		{"Corrupted", key, enc[:len(enc)-4], "", ErrInvalidCiphertext},
+		{"Not base64", key, "enc:!!", "", ErrInvalidCiphertext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecryptValue(tt.key, tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err mismatch: got %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("value mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptConfigLayers(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	s, _ := GenerateConfigKey()
+	key, _ := ParseConfigKey(s)
+	t.Setenv("QCRYPT_CONFIG_KEY", s)
+
+	encrypt := func(v string) string {
+		enc, err := EncryptValue(key, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return enc
+	}
+
+	cmdline := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	cmdline.String("app.flag", "", "flag")
+	if err := cmdline.Parse([]string{"--app.flag=" + encrypt("from-flag")}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("QCRYPT_APP_ENV", encrypt("from-env"))
+
+	a := &Application{envPrefix: "qcrypt", cmdline: cmdline}
+	viper.BindPFlags(cmdline)
+	viper.AutomaticEnv()
+	viper.SetEnvPrefix(a.envPrefix)
+	viper.SetEnvKeyReplacer(envKeyReplacer)
+	viper.BindEnv("app.env")
+	viper.SetDefault("app.default", encrypt("from-default"))
+	if err := viper.MergeConfigMap(map[string]interface{}{"app": map[string]interface{}{"file": encrypt("from-file")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.decryptConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, want := range map[string]string{"app.flag": "from-flag", "app.env": "from-env", "app.default": "from-default", "app.file": "from-file"} {
+		if got := viper.GetString(k); got != want {
+			t.Errorf("decryptConfig() %s = %q, want %q", k, got, want)
+		}
+	}
+	if got := cmdline.Lookup("app.flag").Value.String(); got != "from-flag" {
+		t.Errorf("decryptConfig() flag value = %q", got)
+	}
+
+	// reload of config file or remote config must not be shadowed by decrypted values
+	if err := viper.MergeConfigMap(map[string]interface{}{"app": map[string]interface{}{"default": "reloaded", "file": "reloaded"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"app.default", "app.file"} {
+		if got := viper.GetString(k); got != "reloaded" {
+			t.Errorf("%s after reload = %q, want reloaded", k, got)
+		}
+	}
+}
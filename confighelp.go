@@ -0,0 +1,117 @@
+package qapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// ConfigKeyInfo describes a config key for --help-config
+type ConfigKeyInfo struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Default     string `json:"default"`
+	Env         string `json:"env"`
+	Flag        string `json:"flag,omitempty"`
+	Description string `json:"description"`
+}
+
+var (
+	registeredKeysMu sync.Mutex
+	registeredKeys   = make(map[string]ConfigKeyInfo)
+)
+
+// RegisterConfigKey set the default value of a config key which has no flag and register its description,
+// registered keys are listed by --help-config
+func RegisterConfigKey(key string, defaultValue interface{}, description string) {
+	key = strings.ToLower(key)
+
+	viper.SetDefault(key, defaultValue)
+
+	registeredKeysMu.Lock()
+	defer registeredKeysMu.Unlock()
+
+	registeredKeys[key] = ConfigKeyInfo{
+		Key:         key,
+		Type:        fmt.Sprintf("%T", defaultValue),
+		Default:     fmt.Sprint(defaultValue),
+		Description: description,
+	}
+}
+
+// configKeys return all flags and registered keys, sorted by key
+func (a *Application) configKeys() []ConfigKeyInfo {
+	keys := make(map[string]ConfigKeyInfo)
+
+	registeredKeysMu.Lock()
+	for k, info := range registeredKeys {
+		keys[k] = info
+	}
+	registeredKeysMu.Unlock()
+
+	a.cmdline.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+
+		name := "--" + f.Name
+		if len(f.Shorthand) > 0 {
+			name = "-" + f.Shorthand + ", " + name
+		}
+
+		keys[f.Name] = ConfigKeyInfo{
+			Key:         f.Name,
+			Type:        f.Value.Type(),
+			Default:     f.DefValue,
+			Flag:        name,
+			Description: f.Usage,
+		}
+	})
+
+	infos := make([]ConfigKeyInfo, 0, len(keys))
+	for k, info := range keys {
+		info.Env = a.envName(k)
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+
+	return infos
+}
+
+func (a *Application) showConfigHelp(w io.Writer, format string) error {
+	infos := a.configKeys()
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	case "", "markdown", "md":
+		mdEscape := strings.NewReplacer("|", `\|`, "\n", " ")
+		mdCode := func(s string) string {
+			if len(s) == 0 {
+				return ""
+			}
+			return "`" + mdEscape.Replace(s) + "`"
+		}
+
+		fmt.Fprintf(w, "# %s configuration\n\n", a.name)
+		fmt.Fprintln(w, "| Key | Type | Default | Env | Flag | Description |")
+		fmt.Fprintln(w, "| --- | --- | --- | --- | --- | --- |")
+		for _, info := range infos {
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s |\n",
+				mdCode(info.Key), info.Type, mdCode(info.Default), mdCode(info.Env),
+				mdEscape.Replace(info.Flag), mdEscape.Replace(info.Description))
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown output format: %s", format)
+}
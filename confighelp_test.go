@@ -0,0 +1,89 @@
+package qapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestShowConfigHelp(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	RegisterConfigKey("Help.Timeout", 3*time.Second, "request timeout | per call")
+
+	cmdline := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	cmdline.StringP("help.addr", "a", ":8080", "listen address")
+	cmdline.String("help.hidden", "", "hidden flag")
+	cmdline.MarkHidden("help.hidden")
+
+	a := &Application{name: "helpapp", envPrefix: "qhelp", cmdline: cmdline}
+
+	if got := viper.GetDuration("help.timeout"); got != 3*time.Second {
+		t.Errorf("RegisterConfigKey() default = %v", got)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := a.showConfigHelp(buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# helpapp configuration",
+		"| `help.addr` | string | `:8080` | `QHELP_HELP_ADDR` | -a, --help.addr | listen address |",
+		"| `help.timeout` | time.Duration | `3s` | `QHELP_HELP_TIMEOUT` |  | request timeout \\| per call |",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("showConfigHelp() markdown should contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "help.hidden") {
+		t.Errorf("showConfigHelp() should skip hidden flags:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := a.showConfigHelp(buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var infos []ConfigKeyInfo
+	if err := json.Unmarshal(buf.Bytes(), &infos); err != nil {
+		t.Fatalf("showConfigHelp() json: %v", err)
+	}
+	found := false
+	for _, info := range infos {
+		if info.Key == "help.addr" {
+			found = info.Flag == "-a, --help.addr" && info.Env == "QHELP_HELP_ADDR" && info.Default == ":8080"
+		}
+	}
+	if !found {
+		t.Errorf("showConfigHelp() json should contain help.addr: %+v", infos)
+	}
+
+	if err := a.showConfigHelp(buf, "xml"); err == nil {
+		t.Error("showConfigHelp() with unknown format expected error")
+	}
+}
+
+func TestRunHelpConfigOutput(t *testing.T) {
+	out, code := runApp(t, t.TempDir(), "--help-config", "-o", "json")
+	if code != 0 {
+		t.Errorf("app exit code = %d", code)
+	}
+
+	// the whole stdout is the json doc, ex generated in CI
+	var infos []ConfigKeyInfo
+	if err := json.Unmarshal([]byte(out), &infos); err != nil {
+		t.Fatalf("app stdout is not the config json: %v\n%s", err, out)
+	}
+	found := false
+	for _, info := range infos {
+		found = found || info.Key == FlagHelpConfig
+	}
+	if !found {
+		t.Errorf("config json should contain %s: %+v", FlagHelpConfig, infos)
+	}
+}
@@ -0,0 +1,118 @@
+package qapp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// DefaultCrashKeep is the default number of crash reports kept
+const DefaultCrashKeep = 10
+
+// panicError is an error recovered from panic with the stack
+type panicError struct {
+	msg   string
+	stack string
+}
+
+func (e *panicError) Error() string {
+	return e.msg
+}
+
+// WithCrashReport write a crash report to dir when app exits on init error or daemon error,
+// only the latest keep reports are kept, keep <= 0 means DefaultCrashKeep
+func WithCrashReport(dir string, keep int) AppOpts {
+	return func(a *Application) {
+		a.crashDir = dir
+		a.crashKeep = keep
+	}
+}
+
+// goroutineDump return stacks of all goroutines
+func goroutineDump() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+func (a *Application) crashReport(err error) []byte {
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "qapp crash report\n\n")
+	fmt.Fprintf(buf, "App:    %s\n", a.name)
+	fmt.Fprintf(buf, "Time:   %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(buf, "Error:  %s\n", err)
+
+	fmt.Fprintf(buf, "\n== Stack ==\n")
+	var pErr *panicError
+	if errors.As(err, &pErr) {
+		buf.WriteString(pErr.stack)
+	} else {
+		buf.WriteString(stack(3))
+	}
+
+	fmt.Fprintf(buf, "\n== Version ==\n")
+	showAppVersion(buf, a.name, "")
+	buf.WriteString("\n")
+	for _, d := range buildDeps {
+		fmt.Fprintf(buf, "  %s %s %s\n", d.Path, d.Version, d.Replace)
+	}
+
+	fmt.Fprintf(buf, "\n== Config ==\n")
+	printConfig(buf, a.effectiveConfig())
+
+	fmt.Fprintf(buf, "\n== Goroutines ==\n")
+	buf.Write(goroutineDump())
+
+	return buf.Bytes()
+}
+
+// writeCrashReport write crash report to crash dir if enabled and rotate old reports
+func (a *Application) writeCrashReport(err error) {
+	if len(a.crashDir) == 0 {
+		return
+	}
+
+	if err := os.MkdirAll(a.crashDir, 0o755); err != nil {
+		log.WithError(err).Error("Create crash dir fail")
+		return
+	}
+
+	name := filepath.Join(a.crashDir, fmt.Sprintf("crash-%s-%s-%d.txt", a.name, time.Now().Format("20060102T150405.000"), os.Getpid()))
+	if err := os.WriteFile(name, a.crashReport(err), 0o600); err != nil {
+		log.WithError(err).Error("Write crash report fail")
+		return
+	}
+	log.Errorf("Crash report is written to %s", name)
+
+	a.rotateCrashReports()
+}
+
+func (a *Application) rotateCrashReports() {
+	keep := a.crashKeep
+	if keep <= 0 {
+		keep = DefaultCrashKeep
+	}
+
+	files, err := filepath.Glob(filepath.Join(a.crashDir, fmt.Sprintf("crash-%s-*.txt", a.name)))
+	if err != nil {
+		return
+	}
+
+	sort.Strings(files) // time in name is sortable
+	for i := 0; i < len(files)-keep; i++ {
+		if err := os.Remove(files[i]); err != nil {
+			log.WithError(err).Warn("Remove old crash report fail")
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package qapp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestCrashReport(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set("db.password", "p@ss")
+
+	a := &Application{name: "crashapp", cmdline: pflag.NewFlagSet("test", pflag.ContinueOnError)}
+
+	report := string(a.crashReport(&panicError{msg: "panic:boom", stack: "fake stack\n"}))
+	for _, want := range []string{"App:    crashapp", "Error:  panic:boom", "== Stack ==\nfake stack\n", "== Version ==", "== Config ==", "== Goroutines =="} {
+		if !strings.Contains(report, want) {
+			t.Errorf("crashReport() should contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "p@ss") {
+		t.Errorf("crashReport() should mask secrets:\n%s", report)
+	}
+
+	report = string(a.crashReport(errors.New("daemon failed")))
+	if !strings.Contains(report, "Error:  daemon failed") || !strings.Contains(report, "TestCrashReport") {
+		t.Errorf("crashReport() should contain the caller stack of a plain error:\n%s", report)
+	}
+}
+
+func TestWriteCrashReport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "crash")
+	a := &Application{name: "crashapp", cmdline: pflag.NewFlagSet("test", pflag.ContinueOnError), crashDir: dir, crashKeep: 2}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// old reports sort before new ones by the time in name
+	for _, name := range []string{"crash-crashapp-20000101T000000.000-1.txt", "crash-crashapp-20000101T000001.000-1.txt", "crash-other-20000101T000000.000-1.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a.writeCrashReport(errors.New("init failed"))
+
+	files, _ := filepath.Glob(filepath.Join(dir, "crash-crashapp-*.txt"))
+	if len(files) != 2 {
+		t.Fatalf("crash reports = %v, want 2 kept", files)
+	}
+	if filepath.Base(files[0]) != "crash-crashapp-20000101T000001.000-1.txt" {
+		t.Errorf("the oldest report should be removed: %v", files)
+	}
+
+	data, err := os.ReadFile(files[1])
+	if err != nil || !strings.Contains(string(data), "Error:  init failed") {
+		t.Errorf("new report = %q, %v", data, err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "crash-other-20000101T000000.000-1.txt")); err != nil {
+		t.Errorf("reports of other apps should be kept: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package qapp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// WithDotEnv set default .env files loaded before binding envs, files could also be set by --env-file,
+// envs already set in the real environment always win, and earlier files win over later ones
+func WithDotEnv(paths ...string) AppOpts {
+	return func(a *Application) {
+		a.dotEnvFiles = paths
+	}
+}
+
+// loadDotEnv load .env files to the environment, missing files are ignored
+func loadDotEnv(paths []string) error {
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				log.WithError(err).Debug("Skip .env file")
+				continue
+			}
+			return err
+		}
+
+		envs, err := parseDotEnv(string(data), os.LookupEnv)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+
+		for _, e := range envs {
+			if _, ok := os.LookupEnv(e[0]); ok { // real env wins
+				continue
+			}
+			if err = os.Setenv(e[0], e[1]); err != nil {
+				return err
+			}
+		}
+		log.Tracef("Load .env file %s", p)
+	}
+	return nil
+}
+
+// parseDotEnv parse .env content, return key/value pairs in order.
+// it supports "export " prefix, # comments, single quoted literal, double quoted value with escapes
+// and ${VAR}/$VAR expansion in unquoted or double quoted value, lookup is used for vars not defined before in the file
+func parseDotEnv(src string, lookup func(string) (string, bool)) ([][2]string, error) {
+	var (
+		envs   [][2]string
+		vars   = make(map[string]string)
+		lineNo = 0
+	)
+
+	expandLookup := func(name string) string {
+		if name == "$" { // "$$" is an escaped "$"
+			return "$"
+		}
+		if v, ok := lookup(name); ok {
+			return v
+		}
+		return vars[name]
+	}
+
+	for len(src) > 0 {
+		var line string
+		line, src, _ = strings.Cut(src, "\n")
+		lineNo++
+
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing '='", lineNo)
+		}
+
+		key = strings.TrimSpace(key)
+		if !isEnvName(key) {
+			return nil, fmt.Errorf("line %d: invalid name %q", lineNo, key)
+		}
+
+		value = strings.TrimLeft(value, " \t")
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNo)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			// double quoted value could span multiple lines
+			v, rest, n, err := parseDoubleQuoted(value[1:], src)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			value, src = os.Expand(v, expandLookup), rest
+			lineNo += n
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			value = os.Expand(strings.TrimSpace(value), expandLookup)
+		}
+
+		vars[key] = value
+		envs = append(envs, [2]string{key, value})
+	}
+
+	return envs, nil
+}
+
+// parseDoubleQuoted parse value after the open quote, it consumes following lines from rest if the value spans lines
+func parseDoubleQuoted(value, rest string) (string, string, int, error) {
+	var (
+		sb    strings.Builder
+		lines = 0
+	)
+
+	for {
+		for i := 0; i < len(value); i++ {
+			c := value[i]
+			switch {
+			case c == '"':
+				return sb.String(), rest, lines, nil
+			case c == '\\' && i+1 < len(value):
+				i++
+				switch value[i] {
+				case 'n':
+					sb.WriteByte('\n')
+				case 't':
+					sb.WriteByte('\t')
+				case 'r':
+					sb.WriteByte('\r')
+				case '$': // keep "\$" escaped for os.Expand
+					sb.WriteString("$$")
+				default:
+					sb.WriteByte(value[i])
+				}
+			case c == '$' && i+1 < len(value) && value[i+1] == '$':
+				sb.WriteString("$$")
+				i++
+			default:
+				sb.WriteByte(c)
+			}
+		}
+
+		if len(rest) == 0 {
+			return "", "", lines, errors.New("unterminated double quote")
+		}
+
+		sb.WriteByte('\n')
+		value, rest, _ = strings.Cut(rest, "\n")
+		lines++
+	}
+}
+
+func isEnvName(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i, c := range s {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
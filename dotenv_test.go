@@ -0,0 +1,59 @@
+package qapp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	env := map[string]string{"HOME": "/home/qapp", "QAPP_ADDR": ":8080"}
+	lookup := func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    [][2]string
+		wantErr bool
+	}{
+		{
+			name: "Plain and comments",
+			src:  "# comment\nA=1\n\nexport B = two # inline\nC=x#y\n",
+			want: [][2]string{{"A", "1"}, {"B", "two"}, {"C", "x#y"}},
+		},
+		{
+			name: "Quoting",
+			src:  "A='${HOME} # raw'\nB=\"line1\\nline2 \\\"q\\\"\"\nC=\"multi\nline\"\n",
+			want: [][2]string{{"A", "${HOME} # raw"}, {"B", "line1\nline2 \"q\""}, {"C", "multi\nline"}},
+		},
+		{
+			name: "Expansion",
+			src:  "DIR=$HOME/data\nLOG=\"${DIR}/log\"\nADDR=${QAPP_ADDR}\nPRICE=\"\\$5 $$6\"\n",
+			want: [][2]string{{"DIR", "/home/qapp/data"}, {"LOG", "/home/qapp/data/log"}, {"ADDR", ":8080"}, {"PRICE", "$5 $6"}},
+		},
+		{
+			name:    "Missing equal",
+			src:     "A\n",
+			wantErr: true,
+		},
+		{
+			name:    "Unterminated quote",
+			src:     "A=\"abc\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDotEnv(tt.src, lookup)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err mismatch: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
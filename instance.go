@@ -0,0 +1,55 @@
+package qapp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"time"
+)
+
+// InstanceInfo identify a running app instance
+type InstanceInfo struct {
+	ID        string
+	Hostname  string
+	PID       int
+	StartTime time.Time
+	Uptime    string
+}
+
+var (
+	instanceID   = newInstanceID(rand.Reader)
+	hostname, _  = os.Hostname()
+	appStartTime = time.Now()
+)
+
+// newInstanceID return 8 random bytes in hex, it falls back to pid and start time if r fails
+func newInstanceID(r io.Reader) string {
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(r, b); err != nil {
+		binary.BigEndian.PutUint64(b, uint64(time.Now().UnixNano())^uint64(os.Getpid())<<32)
+	}
+	return hex.EncodeToString(b)
+}
+
+// InstanceID return the random id generated at app start
+func InstanceID() string {
+	return instanceID
+}
+
+// Uptime return duration since app start
+func Uptime() time.Duration {
+	return time.Since(appStartTime)
+}
+
+// Instance return the instance info of app
+func Instance() InstanceInfo {
+	return InstanceInfo{
+		ID:        instanceID,
+		Hostname:  hostname,
+		PID:       os.Getpid(),
+		StartTime: appStartTime,
+		Uptime:    Uptime().Truncate(time.Second).String(),
+	}
+}
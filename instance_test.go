@@ -0,0 +1,49 @@
+package qapp
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestNewInstanceID(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        io.Reader
+		expected string
+	}{
+		{"Full read", strings.NewReader("01234567"), "3031323334353637"},
+		{"One byte reads", iotest.OneByteReader(strings.NewReader("01234567")), "3031323334353637"},
+		{"Short read", strings.NewReader("abc"), ""},
+		{"Read error", iotest.ErrReader(errors.New("no entropy")), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := newInstanceID(tt.r)
+			if len(id) != 16 || id == strings.Repeat("0", 16) {
+				t.Fatalf("newInstanceID() = %q, expected 16 hex chars", id)
+			}
+			if tt.expected != "" && id != tt.expected {
+				t.Errorf("newInstanceID() = %q, expected %q", id, tt.expected)
+			}
+		})
+	}
+}
+
+func TestInstance(t *testing.T) {
+	info := Instance()
+	if info.ID != InstanceID() || info.PID != os.Getpid() || !info.StartTime.Equal(appStartTime) {
+		t.Errorf("Instance() = %+v", info)
+	}
+	if _, err := time.ParseDuration(info.Uptime); err != nil {
+		t.Errorf("Instance() uptime = %q, %v", info.Uptime, err)
+	}
+	if Uptime() <= 0 {
+		t.Errorf("Uptime() = %v", Uptime())
+	}
+}
@@ -1,13 +1,16 @@
 package qapp
 
 import (
+	"context"
 	"errors"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/kkkbird/qapp/qdebugserver"
 	"github.com/kkkbird/qlog"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
@@ -15,14 +18,104 @@ import (
 // Predefined errors
 var (
 	ErrShowVersion = errors.New("ErrShowVersion")
+	ErrPrintConfig = errors.New("ErrPrintConfig")
+	ErrHelpConfig  = errors.New("ErrHelpConfig")
+	ErrExit        = errors.New("ErrExit") // returned by init funcs to exit after a one-shot action, ex a migration
+
+	ErrVersionMismatch   = errors.New("ErrVersionMismatch")   // --version-check is not satisfied, exit code 1
+	ErrVersionConstraint = errors.New("ErrVersionConstraint") // --version-check is invalid, exit code 2
+)
+
+// flags handled by qapp itself
+const (
+	FlagConfigFile   = "file"
+	FlagVersion      = "version"
+	FlagVersionCheck = "version-check"
+	FlagPrintConfig  = "print-config"
+	FlagHelpConfig   = "help-config"
+	FlagOutput       = "output"
+	FlagEnvFile      = "env-file"
 )
 
-func (a *Application) handleFlagsAndEnv() error {
+var envKeyReplacer = strings.NewReplacer(".", "_")
+
+// exitCode return the process exit code of the err returned by run, ex 2 for ErrVersionConstraint,
+// 0 for other exit requests like --version, and 1 for failures like a strict mode error or a fatal exit
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case strings.HasSuffix(err.Error(), ErrVersionMismatch.Error()):
+		return 1
+	case strings.HasSuffix(err.Error(), ErrVersionConstraint.Error()):
+		return 2
+	case isExitRequest(err):
+		return 0
+	}
+	return 1
+}
+
+// isExitRequest check if err is returned by a short-circuit flag like --version
+func isExitRequest(err error) bool {
+	for _, e := range []error{ErrShowVersion, ErrPrintConfig, ErrHelpConfig, ErrExit, ErrVersionMismatch, ErrVersionConstraint} {
+		if strings.HasSuffix(err.Error(), e.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isShortCircuit check if args has a flag printing machine-readable output to stdout and exit, ex --version,
+// it is checked before flags are parsed so logs of the whole run could be kept off stdout
+func isShortCircuit(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "-v", "--" + FlagVersion, "--" + FlagPrintConfig, "--" + FlagHelpConfig:
+			if b, err := strconv.ParseBool(value); hasValue && err == nil && !b {
+				continue
+			}
+			return true
+		case "--" + FlagVersionCheck:
+			return true
+		}
+	}
+	return false
+}
+
+// logToStderr drop the hooks of std logger and write logs to stderr, qlog writes logs to stdout by hooks by default
+func logToStderr() {
+	logger := logrus.StandardLogger()
+	logger.ReplaceHooks(make(logrus.LevelHooks))
+	logger.SetOutput(os.Stderr)
+}
+
+// envName return the env name viper.AutomaticEnv use for key
+func (a *Application) envName(key string) string {
+	name := strings.ToUpper(key)
+	if len(a.envPrefix) > 0 {
+		name = strings.ToUpper(a.envPrefix) + "_" + name
+	}
+	return envKeyReplacer.Replace(name)
+}
+
+func (a *Application) handleFlagsAndEnv(ctx context.Context) error {
 	var err error
 	qdebugserver.RegisteDebugServerPFlags()
 
-	pflag.StringP("file", "f", "app.yml", "config file name")
-	pflag.BoolP("version", "v", false, "show version")
+	pflag.StringP(FlagConfigFile, "f", "app.yml", "config file name")
+	pflag.BoolP(FlagVersion, "v", false, "show version")
+	pflag.String(FlagVersionCheck, "", "check version against a semver constraint and exit, exit code is 1 if not satisfied")
+	pflag.Bool(FlagPrintConfig, false, "print effective config with sources and exit")
+	pflag.Bool(FlagHelpConfig, false, "print reference of all config keys and exit")
+	pflag.StringP(FlagOutput, "o", "", "output format of --version(text, json, yaml or short) and --help-config(markdown or json)")
+	pflag.StringSlice(FlagEnvFile, a.dotEnvFiles, ".env files loaded before binding env")
+	pflag.String(FlagConfigKeyFile, "", "key file to decrypt \"enc:\" config values")
+	RegisterConfigKey(configKeyName, "", "base64 AES key to decrypt \"enc:\" config values, should be set by env")
 
 	if a.preload != nil {
 		if err = a.preload(); err != nil {
@@ -31,43 +124,152 @@ func (a *Application) handleFlagsAndEnv() error {
 		}
 	}
 
-	a.cmdline.Parse(qlog.FilterFlags(os.Args[1:]))
+	if err = a.parseFlags(qlog.FilterFlags(os.Args[1:])); err != nil {
+		return err
+	}
 
 	// bind pflags
 	viper.BindPFlags(pflag.CommandLine)
 
+	// load .env files, it should be done before binding env
+	envFiles, _ := pflag.CommandLine.GetStringSlice(FlagEnvFile)
+	if err = loadDotEnv(envFiles); err != nil {
+		return err
+	}
+
 	// bind env
 	viper.AutomaticEnv()
 	if len(a.envPrefix) > 0 {
 		viper.SetEnvPrefix(a.envPrefix)
 	}
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.SetEnvKeyReplacer(envKeyReplacer)
 
 	// if just show version
-	if viper.GetBool("version") {
-		showAppVersion(os.Stdout, a.name)
+	if viper.GetBool(FlagVersion) {
+		if err = showAppVersion(os.Stdout, a.name, viper.GetString(FlagOutput)); err != nil {
+			return err
+		}
 		return ErrShowVersion
 	}
 
+	// if just check version, Run exits with 1 if not satisfied like pflag exit on error
+	if constraint := viper.GetString(FlagVersionCheck); len(constraint) > 0 {
+		return checkAppVersion(os.Stdout, constraint)
+	}
+
+	// if just show config reference
+	if viper.GetBool(FlagHelpConfig) {
+		if err = a.showConfigHelp(os.Stdout, viper.GetString(FlagOutput)); err != nil {
+			return err
+		}
+		return ErrHelpConfig
+	}
+
 	// read from config file
-	viper.SetConfigFile(viper.GetString("file"))
+	viper.SetConfigFile(viper.GetString(FlagConfigFile))
 	err = viper.ReadInConfig() // Find and read the config file
 
 	if err != nil { // Handle errors reading the config file
 		log.WithError(err).Debug("Read from config fail, use default settings") // it is ok that we cannot read from config file
 	} else {
+		a.configFileLoaded = true
 		// watch config change
 		if a.onConfigFileChanged != nil {
-			viper.WatchConfig()
-			viper.OnConfigChange(func(e fsnotify.Event) {
+			// watch by a private viper, the global one is only written in reloadConfig
+			w := viper.New()
+			w.SetConfigFile(viper.ConfigFileUsed())
+			w.OnConfigChange(func(e fsnotify.Event) {
 				log.Trace("Config file changed:", e.Name)
-				a.onConfigFileChanged()
+				a.reloadConfig()
 			})
+			w.WatchConfig()
+		}
+	}
+
+	// read from remote config, it is merged above the config file
+	if a.remoteConfig != nil {
+		if err = a.remoteConfig.load(ctx); err != nil {
+			log.WithError(err).Warn("Read from remote config fail") // same as config file, it is ok if remote is unavailable
+		}
+	}
+
+	if err = a.applyConfigLayers(); err != nil {
+		return err
+	}
+
+	qdebugserver.SetConfigProvider(func() interface{} { return a.effectiveConfig() })
+
+	// if just print config
+	if viper.GetBool(FlagPrintConfig) {
+		printConfig(os.Stdout, a.effectiveConfig())
+		return ErrPrintConfig
+	}
+
+	if a.strict {
+		if err = a.checkStrict(); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// parseFlags parse command line args, unknown flags fail with a suggestion in strict mode
+func (a *Application) parseFlags(args []string) error {
+	if a.strict {
+		// pflag.CommandLine exits on error, which would skip the suggestion
+		a.cmdline.Init(a.cmdline.Name(), pflag.ContinueOnError)
+	}
+
+	err := a.cmdline.Parse(args)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pflag.ErrHelp): // usage is printed by pflag
+		return ErrExit
+	case a.strict:
+		return a.strictParseError(err)
+	}
+
+	log.WithError(err).Debug("Parse flags fail")
+	return nil
+}
+
+// applyConfigLayers merge remote config and decrypt config values, it should be called after the config file is (re)read
+func (a *Application) applyConfigLayers() error {
+	if err := a.mergeRemoteConfig(); err != nil {
+		return err
+	}
+
+	return a.decryptConfig()
+}
+
+// reloadConfig re-read config file, apply config layers and notify app, the file watch and remote config poll
+// both write the global viper from their own goroutines, so reloads are serialized by configMu
+func (a *Application) reloadConfig() {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
+	var err error
+	if a.configFileLoaded {
+		err = viper.ReadInConfig()
+	} else { // reset config to drop keys removed from remote
+		viper.SetConfigType("yaml")
+		err = viper.ReadConfig(strings.NewReader(""))
+	}
+	if err != nil {
+		log.WithError(err).Warn("Reload config file fail")
+	}
+
+	if err = a.applyConfigLayers(); err != nil {
+		log.WithError(err).Warn("Apply config fail")
+		return
+	}
+
+	if a.onConfigFileChanged != nil {
+		a.onConfigFileChanged()
+	}
+}
+
 // WithCmdLine set init with a timeout
 func WithCmdLine(cmdline *pflag.FlagSet) AppOpts {
 	return func(a *Application) {
@@ -90,10 +292,17 @@ func WithPreload(preload func() error) AppOpts {
 	}
 }
 
-
 // WithEnvPrefix set env prefix, ex WithEnvPrefix("qapp"), envs used by qapp should be prefixed by "QAPP_"
 func WithEnvPrefix(envPrefix string) AppOpts {
 	return func(a *Application) {
 		a.envPrefix = envPrefix
 	}
 }
+
+// WithSecretKeys add key patterns whose values are masked when config is shown,
+// a key is secret if any part of it contains one of the patterns, ex WithSecretKeys("dsn")
+func WithSecretKeys(patterns ...string) AppOpts {
+	return func(a *Application) {
+		a.secretKeys = append(a.secretKeys, patterns...)
+	}
+}
@@ -0,0 +1,132 @@
+package qapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runAppEnv make the test binary run an app with the args in it instead of tests, see runApp
+const (
+	runAppEnv       = "QAPP_TEST_APP_ARGS"
+	runAppStrictEnv = "QAPP_TEST_APP_STRICT" // run the app in strict mode if set
+)
+
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv(runAppEnv); ok {
+		os.Args = append(os.Args[:1], strings.Fields(args)...)
+		var opts []AppOpts
+		if os.Getenv(runAppStrictEnv) != "" {
+			opts = append(opts, WithStrictMode())
+		}
+		New("testapp", opts...).Run()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runApp run the test binary as an app in dir with args, and return its stdout and exit code
+func runApp(t *testing.T, dir string, args ...string) (string, int) {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0])
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), runAppEnv+"="+strings.Join(args, " "))
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("run app: %v", err)
+	}
+	t.Logf("app %v stderr:\n%s", args, stderr.String())
+	return stdout.String(), cmd.ProcessState.ExitCode()
+}
+
+func TestIsShortCircuit(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"--version", "-o", "json"}, true},
+		{[]string{"-f", "app.yml", "-v"}, true},
+		{[]string{"--version=false"}, false},
+		{[]string{"--version-check=>=1.2"}, true},
+		{[]string{"--print-config"}, true},
+		{[]string{"--help-config=true", "-o", "json"}, true},
+		{[]string{"--", "--version"}, false},
+		{[]string{"-f", "app.yml"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := isShortCircuit(tt.args); got != tt.expected {
+			t.Errorf("isShortCircuit(%q) = %v, expected %v", tt.args, got, tt.expected)
+		}
+	}
+}
+
+func TestRunVersionOutput(t *testing.T) {
+	for _, args := range [][]string{{"--version", "-o", "json"}, {"--version", "--output=json"}} {
+		out, code := runApp(t, t.TempDir(), args...)
+		if code != 0 {
+			t.Errorf("app %v exit code = %d", args, code)
+		}
+
+		// logs must not be mixed into the output
+		var info map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &info); err != nil || info["Name"] != "testapp" {
+			t.Errorf("app %v stdout is not the version json: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{nil, 0},
+		{ErrShowVersion, 0},
+		{ErrExit, 0},
+		{ErrVersionMismatch, 1},
+		{ErrVersionConstraint, 2},
+		{errors.New("unknown config keys"), 1},
+		{&panicError{msg: "panic:boom"}, 1},
+	}
+
+	for _, tt := range tests {
+		// init errors are formatted with the func name before reaching Run
+		err := tt.err
+		if err != nil {
+			err = fmt.Errorf("initParams():%s", err)
+		}
+		if code := exitCode(err); code != tt.expected {
+			t.Errorf("exitCode(%v) = %d, expected %d", err, code, tt.expected)
+		}
+	}
+}
+
+func TestRunStrictExitCode(t *testing.T) {
+	t.Setenv(runAppStrictEnv, "1")
+
+	dir := t.TempDir()
+	out, code := runApp(t, dir, "--verison")
+	if code != 1 {
+		t.Errorf("app with unknown flag exit code = %d, expected 1, stdout:\n%s", code, out)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "app.yml"), []byte("unknown:\n  key: 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if out, code = runApp(t, dir); code != 1 {
+		t.Errorf("app with unknown config key exit code = %d, expected 1, stdout:\n%s", code, out)
+	}
+}
@@ -0,0 +1,106 @@
+// Package qclock is a clock abstraction, so timeouts of qcontext, qapp and qhttp could be driven by a fake clock in tests
+package qclock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock provide time functions
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Until(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is time.Timer of a Clock, C() is nil for timers created by AfterFunc
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Real is the clock of package time
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Until(t time.Time) time.Duration        { return time.Until(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type clockKey struct{}
+
+// WithClock attach c to ctx, qcontext functions and qhttp use it instead of package time
+func WithClock(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, c)
+}
+
+// From return the clock attached to ctx, Real if none
+func From(ctx context.Context) Clock {
+	if c, ok := ctx.Value(clockKey{}).(Clock); ok && c != nil {
+		return c
+	}
+	return Real
+}
+
+// deadlineCtx report the deadline of the clock
+type deadlineCtx struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c *deadlineCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *deadlineCtx) Err() error {
+	err := c.Context.Err()
+	if err != nil && context.Cause(c.Context) == context.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// WithTimeout is context.WithTimeout by the clock of ctx
+func WithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	c := From(ctx)
+	if c == Real {
+		return context.WithTimeout(ctx, d)
+	}
+
+	deadline := c.Now().Add(d)
+	if cur, ok := ctx.Deadline(); ok && cur.Before(deadline) {
+		return context.WithCancel(ctx) // parent deadline is sooner
+	}
+
+	childCtx, childCancelCause := context.WithCancelCause(ctx)
+	timer := c.AfterFunc(d, func() {
+		childCancelCause(context.DeadlineExceeded)
+	})
+
+	return &deadlineCtx{childCtx, deadline}, func() {
+		timer.Stop()
+		childCancelCause(nil)
+	}
+}
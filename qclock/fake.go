@@ -0,0 +1,168 @@
+package qclock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock advanced manually by Advance or Set, funcs of AfterFunc run in the goroutine
+// calling Advance, so their effect is visible once Advance returns
+type Fake struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFake return a fake clock start at now
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now implements Clock
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// Since implements Clock
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Until implements Clock
+func (f *Fake) Until(t time.Time) time.Duration {
+	return t.Sub(f.Now())
+}
+
+// After implements Clock
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer implements Clock
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{f: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// AfterFunc implements Clock
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{f: f, fn: fn}
+	t.Reset(d)
+	return t
+}
+
+// Advance move the clock forward by d and fire the expired timers in order
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set move the clock to now and fire the expired timers in order, the clock never goes backward
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	if now.After(f.now) {
+		f.now = now
+	}
+
+	var fired, pending []*fakeTimer
+	for _, t := range f.timers {
+		if t.when.After(f.now) {
+			pending = append(pending, t)
+		} else {
+			fired = append(fired, t)
+		}
+	}
+	f.timers = pending
+	f.cond.Broadcast()
+	f.mu.Unlock()
+
+	sort.SliceStable(fired, func(i, j int) bool { return fired[i].when.Before(fired[j].when) })
+	for _, t := range fired {
+		t.fire()
+	}
+}
+
+// Timers return the number of pending timers
+func (f *Fake) Timers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.timers)
+}
+
+// BlockUntil wait until there are at least n pending timers, it is used to make sure
+// the code under test is waiting on the clock before Advance
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.timers) < n {
+		f.cond.Wait()
+	}
+}
+
+// remove t from pending timers, return whether t was pending, f.mu must be held
+func (f *Fake) remove(t *fakeTimer) bool {
+	for i, pt := range f.timers {
+		if pt == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			f.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	f    *Fake
+	when time.Time
+	c    chan time.Time
+	fn   func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+
+	return t.f.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.f.mu.Lock()
+	active := t.f.remove(t)
+	t.when = t.f.now.Add(d)
+	expired := d <= 0
+	if !expired {
+		t.f.timers = append(t.f.timers, t)
+		t.f.cond.Broadcast()
+	}
+	t.f.mu.Unlock()
+
+	if expired {
+		t.fire()
+	}
+	return active
+}
+
+func (t *fakeTimer) fire() {
+	if t.fn != nil {
+		t.fn()
+		return
+	}
+
+	select {
+	case t.c <- t.when:
+	default:
+	}
+}
@@ -0,0 +1,109 @@
+package qclock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeTimer(t *testing.T) {
+	f := NewFake(epoch)
+
+	t1 := f.NewTimer(time.Second)
+	t2 := f.NewTimer(2 * time.Second)
+	stopped := f.NewTimer(time.Second)
+
+	fired := 0
+	f.AfterFunc(1500*time.Millisecond, func() { fired++ })
+
+	if !stopped.Stop() {
+		t.Errorf("Stop of pending timer should return true")
+	}
+	if got := f.Timers(); got != 3 {
+		t.Errorf("Timers mismatch: got %d, want 3", got)
+	}
+
+	f.Advance(1500 * time.Millisecond)
+
+	select {
+	case now := <-t1.C():
+		if !now.Equal(epoch.Add(time.Second)) {
+			t.Errorf("Fire time mismatch: got %v", now)
+		}
+	default:
+		t.Errorf("Timer should fire")
+	}
+
+	select {
+	case <-t2.C():
+		t.Errorf("Timer should not fire")
+	case <-stopped.C():
+		t.Errorf("Stopped timer should not fire")
+	default:
+	}
+
+	if fired != 1 {
+		t.Errorf("AfterFunc should run once Advance returns, got %d", fired)
+	}
+	if got := f.Since(epoch); got != 1500*time.Millisecond {
+		t.Errorf("Since mismatch: got %v", got)
+	}
+
+	if !t2.Reset(time.Second) {
+		t.Errorf("Reset of pending timer should return true")
+	}
+	f.Advance(500 * time.Millisecond)
+	select {
+	case <-t2.C():
+		t.Errorf("Reset timer should not fire at the old time")
+	default:
+	}
+	f.Advance(500 * time.Millisecond)
+	<-t2.C()
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(epoch)
+
+	done := make(chan struct{})
+	go func() {
+		<-f.After(time.Minute)
+		close(done)
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Minute)
+	<-done
+}
+
+func TestWithTimeout(t *testing.T) {
+	f := NewFake(epoch)
+	ctx := WithClock(context.Background(), f)
+
+	timeoutCtx, cancel := WithTimeout(ctx, time.Hour)
+	defer cancel()
+
+	if deadline, ok := timeoutCtx.Deadline(); !ok || !deadline.Equal(epoch.Add(time.Hour)) {
+		t.Errorf("Deadline mismatch: %v %v", deadline, ok)
+	}
+	if From(timeoutCtx) != f {
+		t.Errorf("Clock should be inherited")
+	}
+
+	f.Advance(time.Hour - time.Nanosecond)
+	if timeoutCtx.Err() != nil {
+		t.Fatalf("Context should not be done: %v", timeoutCtx.Err())
+	}
+
+	f.Advance(time.Nanosecond)
+	if !errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		t.Errorf("Err mismatch: got %v", timeoutCtx.Err())
+	}
+
+	if From(context.Background()) != Real {
+		t.Errorf("Default clock should be Real")
+	}
+}
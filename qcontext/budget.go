@@ -0,0 +1,192 @@
+package qcontext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kkkbird/qapp/qclock"
+)
+
+var (
+	ErrBudgetExhausted = errors.New("deadline budget exhausted")
+)
+
+type budgetKey struct{}
+
+type reservationKey struct{}
+
+// Budget record where the deadline budget of a request goes
+type Budget struct {
+	clock    qclock.Clock
+	start    time.Time
+	deadline time.Time
+	hasDL    bool
+
+	mu    sync.Mutex
+	names []string
+	spent map[string]time.Duration
+}
+
+// WithBudget attach a Budget to ctx, the budget total is the remaining time to the deadline of ctx,
+// or the elapsed time if ctx has no deadline. time is measured by qclock.From(ctx)
+func WithBudget(ctx context.Context) (context.Context, *Budget) {
+	clock := qclock.From(ctx)
+	b := &Budget{
+		clock: clock,
+		start: clock.Now(),
+		spent: make(map[string]time.Duration),
+	}
+	b.deadline, b.hasDL = ctx.Deadline()
+
+	return context.WithValue(ctx, budgetKey{}, b), b
+}
+
+// BudgetFrom return the Budget attached to ctx, nil if none
+func BudgetFrom(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
+
+// Add record d spent on name
+func (b *Budget) Add(name string, d time.Duration) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.spent[name]; !ok {
+		b.names = append(b.names, name)
+	}
+	b.spent[name] += d
+}
+
+// Spent return the time spent on name
+func (b *Budget) Spent(name string) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.spent[name]
+}
+
+// Total return the budget total
+func (b *Budget) Total() time.Duration {
+	if b == nil {
+		return 0
+	}
+	if b.hasDL {
+		return b.deadline.Sub(b.start)
+	}
+	return b.clock.Since(b.start)
+}
+
+// Fraction return the fraction of budget total spent on name
+func (b *Budget) Fraction(name string) float64 {
+	total := b.Total()
+	if total <= 0 {
+		return 0
+	}
+	return float64(b.Spent(name)) / float64(total)
+}
+
+// String return the budget usage, ex "spent 80% on db (800ms), 10% on http (100ms) of 1s"
+func (b *Budget) String() string {
+	if b == nil {
+		return ""
+	}
+
+	total := b.Total()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	parts := make([]string, 0, len(b.names))
+	for _, name := range b.names {
+		pct := 0.0
+		if total > 0 {
+			pct = float64(b.spent[name]) * 100 / float64(total)
+		}
+		parts = append(parts, fmt.Sprintf("%.0f%% on %s (%s)", pct, name, b.spent[name]))
+	}
+
+	if len(parts) == 0 {
+		return fmt.Sprintf("spent nothing of %s", total)
+	}
+	return fmt.Sprintf("spent %s of %s", strings.Join(parts, ", "), total)
+}
+
+// Reserve return a context for a sub call with fraction of the remaining budget of ctx, but at least floor.
+// if the remaining budget is less than floor, the returned context is canceled with ErrBudgetExhausted.
+// the time between Reserve and cancel is recorded to the Budget of ctx as name
+func Reserve(ctx context.Context, name string, fraction float64, floor time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return reserve(ctx, name, 0, floor)
+	}
+	return reserve(ctx, name, time.Duration(float64(qclock.From(ctx).Until(deadline))*fraction), floor)
+}
+
+// ReserveFixed is Reserve with a fixed slice d of the remaining budget
+func ReserveFixed(ctx context.Context, name string, d time.Duration, floor time.Duration) (context.Context, context.CancelFunc) {
+	return reserve(ctx, name, d, floor)
+}
+
+// reserve d for name, d 0 means no timeout
+func reserve(ctx context.Context, name string, d time.Duration, floor time.Duration) (context.Context, context.CancelFunc) {
+	clock := qclock.From(ctx)
+	start := clock.Now()
+	budget := BudgetFrom(ctx)
+
+	ctx = context.WithValue(ctx, reservationKey{}, name)
+
+	childCtx, childCancelCause := context.WithCancelCause(ctx)
+	done := func() {
+		childCancelCause(nil)
+		budget.Add(name, clock.Since(start))
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && clock.Until(deadline) < floor {
+		childCancelCause(ErrBudgetExhausted)
+		return childCtx, sync.OnceFunc(done)
+	}
+
+	if d > 0 && d < floor {
+		d = floor
+	}
+
+	if d > 0 {
+		timeoutCtx, cancel := qclock.WithTimeout(childCtx, d)
+		return timeoutCtx, sync.OnceFunc(func() {
+			cancel()
+			done()
+		})
+	}
+
+	return childCtx, sync.OnceFunc(done)
+}
+
+// Track record the time of a call to the Budget of ctx, it is used by call wrappers like qhttp.Get, ex:
+//
+//	defer qcontext.Track(ctx, "http")()
+//
+// nothing is recorded if ctx is from Reserve, which records the time itself
+func Track(ctx context.Context, name string) func() {
+	budget := BudgetFrom(ctx)
+	if budget == nil || ctx.Value(reservationKey{}) != nil {
+		return func() {}
+	}
+
+	start := budget.clock.Now()
+	return func() {
+		budget.Add(name, budget.clock.Since(start))
+	}
+}
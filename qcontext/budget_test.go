@@ -0,0 +1,136 @@
+package qcontext
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	const slack = 20 * time.Millisecond
+
+	tests := []struct {
+		name           string
+		timeout        time.Duration
+		reserve        func(ctx context.Context) (context.Context, context.CancelFunc)
+		expectedWithin time.Duration // expected time to the deadline of the reserved ctx
+		expectedCause  error
+	}{
+		{
+			name:    "Fraction of remaining",
+			timeout: 1 * time.Second,
+			reserve: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return Reserve(ctx, "db", 0.2, 0)
+			},
+			expectedWithin: 200 * time.Millisecond,
+		},
+		{
+			name:    "Fraction raised to floor",
+			timeout: 1 * time.Second,
+			reserve: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return Reserve(ctx, "db", 0.1, 300*time.Millisecond)
+			},
+			expectedWithin: 300 * time.Millisecond,
+		},
+		{
+			name:    "Fixed slice limited by remaining",
+			timeout: 100 * time.Millisecond,
+			reserve: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return ReserveFixed(ctx, "http", time.Second, 0)
+			},
+			expectedWithin: 100 * time.Millisecond,
+		},
+		{
+			name:    "Remaining less than floor",
+			timeout: 100 * time.Millisecond,
+			reserve: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return Reserve(ctx, "http", 0.5, time.Second)
+			},
+			expectedCause: ErrBudgetExhausted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			ctx, budget := WithBudget(ctx)
+
+			subCtx, subCancel := tt.reserve(ctx)
+
+			if tt.expectedCause != nil {
+				if gotCause := context.Cause(subCtx); !errors.Is(gotCause, tt.expectedCause) {
+					t.Errorf("Cause mismatch: got %v, want %v", gotCause, tt.expectedCause)
+				}
+				subCancel()
+				return
+			}
+
+			deadline, ok := subCtx.Deadline()
+			if !ok {
+				t.Fatalf("Reserved context has no deadline")
+			}
+			if within := time.Until(deadline); within > tt.expectedWithin+slack || within < tt.expectedWithin-slack {
+				t.Errorf("Deadline mismatch: within %v, want %v", within, tt.expectedWithin)
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			subCancel()
+			subCancel() // record only once
+
+			spent := budget.Spent("db") + budget.Spent("http")
+			if spent < 50*time.Millisecond || spent > 50*time.Millisecond+slack {
+				t.Errorf("Spent mismatch: got %v", spent)
+			}
+		})
+	}
+}
+
+func TestTrack(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ctx, budget := WithBudget(ctx)
+
+	done := Track(ctx, "db")
+	time.Sleep(50 * time.Millisecond)
+	done()
+
+	// time in reserved ctx is recorded by the reservation only
+	subCtx, subCancel := Reserve(ctx, "http", 0.5, 0)
+	done = Track(subCtx, "db")
+	time.Sleep(20 * time.Millisecond)
+	done()
+	subCancel()
+
+	if got := budget.Fraction("db"); got < 0.05 || got > 0.07 {
+		t.Errorf("db fraction mismatch: got %v", got)
+	}
+	if got := budget.Spent("http"); got < 20*time.Millisecond {
+		t.Errorf("http spent mismatch: got %v", got)
+	}
+	if s := budget.String(); !strings.HasPrefix(s, "spent 5% on db") {
+		t.Errorf("String mismatch: %s", s)
+	}
+}
+
+func TestNilBudget(t *testing.T) {
+	budget := BudgetFrom(context.Background())
+
+	budget.Add("db", time.Second)
+	if got := budget.Spent("db"); got != 0 {
+		t.Errorf("Spent of nil budget: got %v", got)
+	}
+	if got := budget.Total(); got != 0 {
+		t.Errorf("Total of nil budget: got %v", got)
+	}
+	if got := budget.Fraction("db"); got != 0 {
+		t.Errorf("Fraction of nil budget: got %v", got)
+	}
+	if got := budget.String(); got != "" {
+		t.Errorf("String of nil budget: got %q", got)
+	}
+}
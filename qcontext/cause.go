@@ -0,0 +1,98 @@
+package qcontext
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SignalCause is the cancel cause when the app receives a signal
+type SignalCause struct {
+	Signal os.Signal
+}
+
+func (c *SignalCause) Error() string {
+	return fmt.Sprintf("received signal %s", c.Signal)
+}
+
+// DaemonFailedCause is the cancel cause when a daemon exits with error or panic
+type DaemonFailedCause struct {
+	Name string
+	Err  error
+}
+
+func (c *DaemonFailedCause) Error() string {
+	return fmt.Sprintf("daemon %s failed: %s", c.Name, c.Err)
+}
+
+func (c *DaemonFailedCause) Unwrap() error {
+	return c.Err
+}
+
+// ReloadCause is the cancel cause when the app reloads, ex config changed
+type ReloadCause struct {
+	Reason string
+}
+
+func (c *ReloadCause) Error() string {
+	return fmt.Sprintf("reload: %s", c.Reason)
+}
+
+// AdminCause is the cancel cause when an admin requests shutdown, ex from debug server
+type AdminCause struct {
+	Reason string
+}
+
+func (c *AdminCause) Error() string {
+	return fmt.Sprintf("admin request: %s", c.Reason)
+}
+
+// causedBy is a cause which happens after the parent is done, ex timeout after shutdown by signal,
+// errors.Is and errors.As match both err and parent
+type causedBy struct {
+	err    error
+	parent error
+}
+
+func (c *causedBy) Error() string {
+	return c.err.Error()
+}
+
+func (c *causedBy) Unwrap() []error {
+	return []error{c.err, c.parent}
+}
+
+// withParentCause return err caused by the cause of parent ctx, err is returned if parent has no cause
+func withParentCause(err error, parent context.Context) error {
+	cause := context.Cause(parent)
+	if cause == nil {
+		return err
+	}
+	return &causedBy{err: err, parent: cause}
+}
+
+// CauseChain render why ctx is done, ex "context timeout <- received signal terminated",
+// return empty string if ctx is not done
+func CauseChain(ctx context.Context) string {
+	return RenderCause(context.Cause(ctx))
+}
+
+// RenderCause render a cause and the causes it happens after
+func RenderCause(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	parts := make([]string, 0, 2)
+	for {
+		c, ok := err.(*causedBy)
+		if !ok {
+			parts = append(parts, err.Error())
+			break
+		}
+		parts = append(parts, c.err.Error())
+		err = c.parent
+	}
+	return strings.Join(parts, " <- ")
+}
@@ -0,0 +1,101 @@
+package qcontext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCauseChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		cause    error
+		release  bool
+		expected string
+	}{
+		{
+			name:     "Released with signal",
+			cause:    &SignalCause{Signal: syscall.SIGTERM},
+			release:  true,
+			expected: "received signal terminated",
+		},
+		{
+			name:     "Timeout after signal",
+			cause:    &SignalCause{Signal: syscall.SIGTERM},
+			expected: "context timeout <- received signal terminated",
+		},
+		{
+			name:     "Timeout after daemon failed",
+			cause:    &DaemonFailedCause{Name: "worker", Err: errors.New("boom")},
+			expected: "context timeout <- daemon worker failed: boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parentCtx, parentCancel := context.WithCancelCause(context.Background())
+
+			release := make(chan struct{})
+			if tt.release {
+				close(release)
+			}
+
+			childCtx, childCancel := WithDelayedContextUntil(parentCtx, 10*time.Millisecond, release)
+			defer childCancel()
+
+			if got := CauseChain(childCtx); got != "" {
+				t.Errorf("CauseChain of running ctx: got %q", got)
+			}
+
+			parentCancel(tt.cause)
+			<-childCtx.Done()
+
+			if got := CauseChain(childCtx); got != tt.expected {
+				t.Errorf("CauseChain mismatch: got %q, want %q", got, tt.expected)
+			}
+
+			// typed cause is still reachable through the chain
+			cause := context.Cause(childCtx)
+			if !errors.Is(cause, tt.cause) {
+				t.Errorf("Cause %v is not %v", cause, tt.cause)
+			}
+			if !tt.release && !errors.Is(cause, ErrDelayedContextTimeout) {
+				t.Errorf("Cause %v is not %v", cause, ErrDelayedContextTimeout)
+			}
+		})
+	}
+}
+
+func TestRenderCause(t *testing.T) {
+	var (
+		reload = &ReloadCause{Reason: "config changed"}
+		admin  = &AdminCause{Reason: "maintenance"}
+	)
+
+	tests := []struct {
+		cause    error
+		expected string
+	}{
+		{nil, ""},
+		{context.Canceled, "context canceled"},
+		{reload, "reload: config changed"},
+		{&causedBy{err: ErrBudgetExhausted, parent: &causedBy{err: ErrDelayedContextTimeout, parent: admin}},
+			"deadline budget exhausted <- context timeout <- admin request: maintenance"},
+		{fmt.Errorf("wrapped: %w", reload), "wrapped: reload: config changed"},
+	}
+
+	for _, tt := range tests {
+		if got := RenderCause(tt.cause); got != tt.expected {
+			t.Errorf("RenderCause(%v): got %q, want %q", tt.cause, got, tt.expected)
+		}
+	}
+
+	var dErr *DaemonFailedCause
+	err := fmt.Errorf("run: %w", &DaemonFailedCause{Name: "api", Err: context.DeadlineExceeded})
+	if !errors.As(err, &dErr) || dErr.Name != "api" || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DaemonFailedCause not matched: %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"time"
+
+	"github.com/kkkbird/qapp/qclock"
 )
 
 var (
@@ -12,23 +14,36 @@ var (
 
 // delay 0 meas block forever
 func WithDelayedContext(ctx context.Context, delay time.Duration) (context.Context, context.CancelFunc) {
+	return WithDelayedContextUntil(ctx, delay, nil)
+}
+
+// WithDelayedContextUntil is WithDelayedContext which could be released early, after ctx is done,
+// the child is canceled with the cause of ctx once release is closed, or with ErrDelayedContextTimeout caused by the cause of ctx after delay.
+// delay 0 means wait release forever, and nil release is never closed. the delay is timed by qclock.From(ctx)
+func WithDelayedContextUntil(ctx context.Context, delay time.Duration, release <-chan struct{}) (context.Context, context.CancelFunc) {
 	childCtx, childCancelCause := context.WithCancelCause(context.WithoutCancel(ctx))
 
-	if delay == 0 {
+	if delay == 0 && release == nil {
 		return childCtx, func() {
 			childCancelCause(nil)
 		}
 	}
 
 	stop := context.AfterFunc(ctx, func() {
-		timer := time.NewTimer(delay)
-		defer timer.Stop()
+		var timeout <-chan time.Time
+		if delay > 0 {
+			timer := qclock.From(ctx).NewTimer(delay)
+			defer timer.Stop()
+			timeout = timer.C()
+		}
 
 		select {
 		case <-childCtx.Done():
 			return
-		case <-timer.C:
-			childCancelCause(ErrDelayedContextTimeout)
+		case <-release:
+			childCancelCause(context.Cause(ctx))
+		case <-timeout:
+			childCancelCause(withParentCause(ErrDelayedContextTimeout, ctx))
 		}
 	})
 	return childCtx, func() {
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"testing"
 	"time"
+
+	"github.com/kkkbird/qapp/qclock"
 )
 
 func TestWithDelayedContext_Comprehensive(t *testing.T) {
@@ -120,3 +122,100 @@ func TestWithDelayedContext_Comprehensive(t *testing.T) {
 		})
 	}
 }
+
+func TestWithDelayedContextUntil(t *testing.T) {
+	errParent := errors.New("parent cause")
+
+	tests := []struct {
+		name          string
+		delay         time.Duration
+		release       bool // close release after parent cancellation
+		expectedCause error
+		maxElapsed    time.Duration
+	}{
+		{
+			name:          "Released before delay",
+			delay:         time.Second,
+			release:       true,
+			expectedCause: errParent,
+			maxElapsed:    100 * time.Millisecond,
+		},
+		{
+			name:          "Delay before release",
+			delay:         50 * time.Millisecond,
+			release:       false,
+			expectedCause: ErrDelayedContextTimeout,
+			maxElapsed:    150 * time.Millisecond,
+		},
+		{
+			name:          "Released without delay",
+			delay:         0,
+			release:       true,
+			expectedCause: errParent,
+			maxElapsed:    100 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parentCtx, parentCancel := context.WithCancelCause(context.Background())
+			defer parentCancel(nil)
+
+			release := make(chan struct{})
+
+			childCtx, childCancel := WithDelayedContextUntil(parentCtx, tt.delay, release)
+			defer childCancel()
+
+			// release before parent is done should be ignored until parent is done
+			start := time.Now()
+			if tt.release {
+				close(release)
+			}
+
+			select {
+			case <-childCtx.Done():
+				t.Fatalf("Child context closed before parent: %v", context.Cause(childCtx))
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			parentCancel(errParent)
+
+			select {
+			case <-childCtx.Done():
+				if gotCause := context.Cause(childCtx); !errors.Is(gotCause, tt.expectedCause) {
+					t.Errorf("Cause mismatch: got %v, want %v", gotCause, tt.expectedCause)
+				}
+				if elapsed := time.Since(start); elapsed > tt.maxElapsed {
+					t.Errorf("Exited too late: elapsed %v", elapsed)
+				}
+			case <-time.After(tt.delay + time.Second):
+				t.Fatalf("Timeout: child context failed to close")
+			}
+		})
+	}
+}
+
+func TestWithDelayedContextFakeClock(t *testing.T) {
+	clock := qclock.NewFake(time.Now())
+
+	parentCtx, parentCancel := context.WithCancel(qclock.WithClock(context.Background(), clock))
+	childCtx, childCancel := WithDelayedContext(parentCtx, time.Hour)
+	defer childCancel()
+
+	parentCancel()
+	clock.BlockUntil(1) // delay timer started
+
+	clock.Advance(time.Hour - time.Second)
+	select {
+	case <-childCtx.Done():
+		t.Fatalf("Child context closed before delay")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	<-childCtx.Done()
+
+	if gotCause := context.Cause(childCtx); !errors.Is(gotCause, ErrDelayedContextTimeout) {
+		t.Errorf("Cause mismatch: got %v, want %v", gotCause, ErrDelayedContextTimeout)
+	}
+}
@@ -0,0 +1,118 @@
+package qcontext
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// deadlineCtx override the deadline of a context, and report DeadlineExceeded by Err if it is
+// canceled by a parent past its deadline
+type deadlineCtx struct {
+	context.Context
+	deadline time.Time
+	ok       bool
+}
+
+func (c *deadlineCtx) Deadline() (time.Time, bool) {
+	return c.deadline, c.ok
+}
+
+func (c *deadlineCtx) Err() error {
+	err := c.Context.Err()
+	if err != nil && context.Cause(c.Context) == context.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// Merge return a context canceled when any of ctxs is done, with the cause of that ctx.
+// values are from the first ctx, and the deadline is the earliest one of ctxs
+func Merge(ctxs ...context.Context) (context.Context, context.CancelFunc) {
+	if len(ctxs) == 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	childCtx, childCancelCause := context.WithCancelCause(ctxs[0])
+
+	stops := make([]func() bool, 0, len(ctxs)-1)
+	for _, ctx := range ctxs[1:] {
+		stops = append(stops, context.AfterFunc(ctx, func() {
+			childCancelCause(context.Cause(ctx))
+		}))
+	}
+
+	// stop watching other parents once child is done
+	context.AfterFunc(childCtx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+	})
+
+	deadline, ok := earliestDeadline(ctxs)
+	return &deadlineCtx{childCtx, deadline, ok}, func() {
+		childCancelCause(nil)
+	}
+}
+
+// Join return a context canceled only when all of ctxs are done, with the cause of the last done ctx.
+// values are from the first ctx, and the deadline is the latest one if all ctxs have deadlines
+func Join(ctxs ...context.Context) (context.Context, context.CancelFunc) {
+	if len(ctxs) == 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	childCtx, childCancelCause := context.WithCancelCause(context.WithoutCancel(ctxs[0]))
+
+	var (
+		mu      sync.Mutex
+		pending = len(ctxs)
+	)
+
+	stops := make([]func() bool, 0, len(ctxs))
+	for _, ctx := range ctxs {
+		stops = append(stops, context.AfterFunc(ctx, func() {
+			mu.Lock()
+			pending--
+			done := pending == 0
+			mu.Unlock()
+
+			if done {
+				childCancelCause(context.Cause(ctx))
+			}
+		}))
+	}
+
+	context.AfterFunc(childCtx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+	})
+
+	deadline, ok := latestDeadline(ctxs)
+	return &deadlineCtx{childCtx, deadline, ok}, func() {
+		childCancelCause(nil)
+	}
+}
+
+func earliestDeadline(ctxs []context.Context) (earliest time.Time, found bool) {
+	for _, ctx := range ctxs {
+		if d, ok := ctx.Deadline(); ok && (!found || d.Before(earliest)) {
+			earliest, found = d, true
+		}
+	}
+	return
+}
+
+func latestDeadline(ctxs []context.Context) (latest time.Time, found bool) {
+	for _, ctx := range ctxs {
+		d, ok := ctx.Deadline()
+		if !ok {
+			return time.Time{}, false
+		}
+		if d.After(latest) {
+			latest = d
+		}
+	}
+	return latest, true
+}
@@ -0,0 +1,172 @@
+package qcontext
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testKey struct{}
+
+var (
+	errCauseA = errors.New("cause a")
+	errCauseB = errors.New("cause b")
+)
+
+func TestMergeAndJoin(t *testing.T) {
+	tests := []struct {
+		name          string
+		join          bool
+		action        func(cancelA, cancelB context.CancelCauseFunc, cancel context.CancelFunc)
+		waitDone      bool
+		expectedCause error
+	}{
+		{
+			name:          "Merge canceled by first parent",
+			action:        func(a, b context.CancelCauseFunc, c context.CancelFunc) { a(errCauseA) },
+			waitDone:      true,
+			expectedCause: errCauseA,
+		},
+		{
+			name:          "Merge canceled by second parent",
+			action:        func(a, b context.CancelCauseFunc, c context.CancelFunc) { b(errCauseB) },
+			waitDone:      true,
+			expectedCause: errCauseB,
+		},
+		{
+			name:          "Merge canceled manually",
+			action:        func(a, b context.CancelCauseFunc, c context.CancelFunc) { c() },
+			waitDone:      true,
+			expectedCause: context.Canceled,
+		},
+		{
+			name:     "Merge stays alive",
+			action:   func(a, b context.CancelCauseFunc, c context.CancelFunc) {},
+			waitDone: false,
+		},
+		{
+			name:     "Join stays alive if one parent is canceled",
+			join:     true,
+			action:   func(a, b context.CancelCauseFunc, c context.CancelFunc) { a(errCauseA) },
+			waitDone: false,
+		},
+		{
+			name: "Join canceled when all parents are canceled",
+			join: true,
+			action: func(a, b context.CancelCauseFunc, c context.CancelFunc) {
+				a(errCauseA)
+				time.Sleep(10 * time.Millisecond) // let a's AfterFunc run first
+				b(errCauseB)
+			},
+			waitDone:      true,
+			expectedCause: errCauseB,
+		},
+		{
+			name:          "Join canceled manually",
+			join:          true,
+			action:        func(a, b context.CancelCauseFunc, c context.CancelFunc) { c() },
+			waitDone:      true,
+			expectedCause: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctxA, cancelA := context.WithCancelCause(context.WithValue(context.Background(), testKey{}, "a"))
+			defer cancelA(nil)
+			ctxB, cancelB := context.WithCancelCause(context.WithValue(context.Background(), testKey{}, "b"))
+			defer cancelB(nil)
+
+			var (
+				ctx    context.Context
+				cancel context.CancelFunc
+			)
+			if tt.join {
+				ctx, cancel = Join(ctxA, ctxB)
+			} else {
+				ctx, cancel = Merge(ctxA, ctxB)
+			}
+			defer cancel()
+
+			if v := ctx.Value(testKey{}); v != "a" {
+				t.Errorf("Value mismatch: got %v, want a", v)
+			}
+
+			tt.action(cancelA, cancelB, cancel)
+
+			if tt.waitDone {
+				select {
+				case <-ctx.Done():
+					if gotCause := context.Cause(ctx); !errors.Is(gotCause, tt.expectedCause) {
+						t.Errorf("Cause mismatch: got %v, want %v", gotCause, tt.expectedCause)
+					}
+				case <-time.After(200 * time.Millisecond):
+					t.Fatalf("Timeout: context failed to close")
+				}
+			} else {
+				select {
+				case <-ctx.Done():
+					t.Errorf("Context closed unexpectedly: %v", context.Cause(ctx))
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+		})
+	}
+}
+
+func TestMergeAndJoinDeadline(t *testing.T) {
+	now := time.Now()
+
+	ctxA, cancelA := context.WithDeadline(context.Background(), now.Add(time.Hour))
+	defer cancelA()
+	ctxB, cancelB := context.WithDeadline(context.Background(), now.Add(time.Minute))
+	defer cancelB()
+
+	merged, cancel := Merge(ctxA, ctxB)
+	defer cancel()
+	if d, ok := merged.Deadline(); !ok || !d.Equal(now.Add(time.Minute)) {
+		t.Errorf("Merge deadline mismatch: got %v %v", d, ok)
+	}
+
+	joined, cancel2 := Join(ctxA, ctxB)
+	defer cancel2()
+	if d, ok := joined.Deadline(); !ok || !d.Equal(now.Add(time.Hour)) {
+		t.Errorf("Join deadline mismatch: got %v %v", d, ok)
+	}
+
+	joined2, cancel3 := Join(ctxA, context.Background())
+	defer cancel3()
+	if _, ok := joined2.Deadline(); ok {
+		t.Errorf("Join should have no deadline if any parent has none")
+	}
+}
+
+func TestMergeAndJoinDeadlineExceeded(t *testing.T) {
+	ctxA, cancelA := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelA()
+	ctxB, cancelB := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelB()
+
+	merged, cancel := Merge(context.Background(), ctxA)
+	defer cancel()
+	joined, cancel2 := Join(ctxA, ctxB)
+	defer cancel2()
+
+	for name, ctx := range map[string]context.Context{"Merge": merged, "Join": joined} {
+		<-ctx.Done()
+		if err := ctx.Err(); err != context.DeadlineExceeded {
+			t.Errorf("%s Err mismatch: got %v, want %v", name, err, context.DeadlineExceeded)
+		}
+		if cause := context.Cause(ctx); cause != context.DeadlineExceeded {
+			t.Errorf("%s Cause mismatch: got %v", name, cause)
+		}
+	}
+
+	// canceled by the caller is still Canceled
+	merged2, cancel3 := Merge(context.Background(), ctxB)
+	cancel3()
+	if err := merged2.Err(); err != context.Canceled {
+		t.Errorf("Merge Err after cancel mismatch: got %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package qdb
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWrongArrayType = errors.New("array scan failed")
+)
+
+// arraySrc return the text of an array column, ok false if src is NULL
+func arraySrc(src interface{}) (data []byte, ok bool, err error) {
+	switch src := src.(type) {
+	case []byte:
+		return src, true, nil
+	case string:
+		return []byte(src), true, nil
+	case nil:
+		return nil, false, nil
+	}
+	return nil, false, ErrWrongArrayType
+}
+
+// parseArray parse a one-dimensional array in text format, ex `{"(1,2)","(3,4)"}`,
+// elements are separated by delim, which is ';' for box and ',' for others. NULL elements are nil
+func parseArray(src []byte, delim byte) ([][]byte, error) {
+	s := bytes.TrimSpace(src)
+
+	// skip dimension decoration, ex "[0:1]={1,2}"
+	if len(s) > 0 && s[0] == '[' {
+		i := bytes.IndexByte(s, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrWrongArrayType, src)
+		}
+		s = bytes.TrimSpace(s[i+1:])
+	}
+
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, fmt.Errorf("%w: %q", ErrWrongArrayType, src)
+	}
+
+	elems := make([][]byte, 0)
+	if len(bytes.TrimSpace(s[1:len(s)-1])) == 0 {
+		return elems, nil
+	}
+
+	for i := 1; i < len(s); {
+		for i < len(s) && isArraySpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+
+		var (
+			elem   []byte
+			quoted bool
+		)
+
+		switch s[i] {
+		case '{': // sub array of multi-dimensional array, kept as is
+			start, depth := i, 0
+			for inQuote := false; i < len(s); i++ {
+				switch c := s[i]; {
+				case c == '\\' && inQuote:
+					i++
+				case c == '"':
+					inQuote = !inQuote
+				case c == '{' && !inQuote:
+					depth++
+				case c == '}' && !inQuote:
+					depth--
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("%w: %q", ErrWrongArrayType, src)
+			}
+			i++
+			elem = s[start:i]
+			quoted = true // never NULL
+		case '"':
+			quoted = true
+			elem = []byte{}
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+				if i < len(s) {
+					elem = append(elem, s[i])
+				}
+			}
+			i++ // closing quote
+		default:
+			for ; i < len(s) && s[i] != delim && s[i] != '}'; i++ {
+				if s[i] == '"' || s[i] == '{' {
+					return nil, fmt.Errorf("%w: %q", ErrWrongArrayType, src)
+				}
+				if s[i] == '\\' {
+					i++
+				}
+				if i < len(s) {
+					elem = append(elem, s[i])
+				}
+			}
+			elem = bytes.TrimRight(elem, " \t\r\n")
+		}
+
+		if !quoted && strings.EqualFold(string(elem), "NULL") {
+			elem = nil
+		}
+		elems = append(elems, elem)
+
+		for i < len(s) && isArraySpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return nil, fmt.Errorf("%w: %q", ErrWrongArrayType, src)
+		}
+		if s[i] == '}' {
+			return elems, nil
+		}
+		if s[i] != delim {
+			return nil, fmt.Errorf("%w: %q", ErrWrongArrayType, src)
+		}
+		i++
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrWrongArrayType, src)
+}
+
+func isArraySpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// appendArrayElem append elem to array text, quoted if needed
+func appendArrayElem(buf *bytes.Buffer, elem string, delim byte) {
+	needQuote := len(elem) == 0 || strings.EqualFold(elem, "NULL")
+	for i := 0; i < len(elem) && !needQuote; i++ {
+		switch c := elem[i]; c {
+		case '"', '\\', '{', '}', delim:
+			needQuote = true
+		default:
+			needQuote = isArraySpace(c)
+		}
+	}
+
+	if !needQuote {
+		buf.WriteString(elem)
+		return
+	}
+
+	buf.WriteByte('"')
+	for i := 0; i < len(elem); i++ {
+		if elem[i] == '"' || elem[i] == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(elem[i])
+	}
+	buf.WriteByte('"')
+}
+
+// ArrayDelimiter is implemented by element types whose array delimiter is not ',', ex Box
+type ArrayDelimiter interface {
+	ArrayDelimiter() byte
+}
+
+// Array is a PostgreSQL array of T, T must be a value type implements driver.Valuer, and *T implements sql.Scanner.
+// NULL elements are scanned by T, ex Array[sql.NullString] or Array[qdb.Null[qdb.Point]], and
+// multi-dimensional arrays are nested Array, ex Array[Array[sql.NullInt64]]. NULL array is scanned as nil
+//
+//	var tags qdb.Array[sql.NullString]
+//	db.QueryRow(sqlstr).Scan(&tags)
+type Array[T driver.Valuer] []T
+
+func (Array[T]) isArray() {}
+
+// ArrayDelimiter implements ArrayDelimiter, it is the delimiter of T
+func (Array[T]) ArrayDelimiter() byte {
+	var v T
+	return arrayDelimiter(v)
+}
+
+func arrayDelimiter(v interface{}) byte {
+	if d, ok := v.(ArrayDelimiter); ok {
+		return d.ArrayDelimiter()
+	}
+	return ','
+}
+
+// Scan implements the Scanner interface.
+func (a *Array[T]) Scan(src interface{}) error {
+	data, ok, err := arraySrc(src)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		*a = nil
+		return nil
+	}
+
+	elems, err := parseArray(data, a.ArrayDelimiter())
+	if err != nil {
+		return err
+	}
+
+	b := make(Array[T], len(elems))
+	for i, e := range elems {
+		scanner, ok := any(&b[i]).(sql.Scanner)
+		if !ok {
+			return fmt.Errorf("*%T does not implement sql.Scanner", b[i])
+		}
+
+		var v interface{}
+		if e != nil {
+			v = e
+		}
+		if err = scanner.Scan(v); err != nil {
+			return fmt.Errorf("%w: element %d: %w", ErrWrongArrayType, i, err)
+		}
+	}
+
+	*a = b
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (a Array[T]) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	var (
+		delim = a.ArrayDelimiter()
+		err   error
+	)
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, e := range a {
+		if i > 0 {
+			buf.WriteByte(delim)
+		}
+
+		var v driver.Value
+		if v, err = e.Value(); err != nil {
+			return nil, err
+		}
+
+		s, null := arrayElemText(v)
+		switch {
+		case null:
+			buf.WriteString("NULL")
+		case isArray(e):
+			buf.WriteString(s) // sub array is not quoted
+		default:
+			appendArrayElem(buf, s, delim)
+		}
+	}
+	buf.WriteByte('}')
+
+	return buf.String(), nil
+}
+
+func isArray(v interface{}) bool {
+	_, ok := v.(interface{ isArray() })
+	return ok
+}
+
+// arrayElemText return the text format of a driver value
+func arrayElemText(v driver.Value) (s string, null bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case []byte:
+		return string(v), false
+	case string:
+		return v, false
+	case int64:
+		return strconv.FormatInt(v, 10), false
+	case float64:
+		return formatFloat(v), false
+	case bool:
+		if v {
+			return "t", false
+		}
+		return "f", false
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999999Z07:00"), false
+	}
+	return fmt.Sprint(v), false
+}
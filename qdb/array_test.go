@@ -0,0 +1,112 @@
+package qdb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testMood string
+
+func (m *testMood) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		*m = testMood(src)
+	case string:
+		*m = testMood(src)
+	default:
+		return errors.New("mood scan failed")
+	}
+	return nil
+}
+
+func (m testMood) Value() (driver.Value, error) {
+	return string(m), nil
+}
+
+func ns(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: true}
+}
+
+func TestArrayRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		new      func() geometry
+		text     string // as output by PostgreSQL
+		expected interface{}
+	}{
+		{"Text with NULL", func() geometry { return new(Array[sql.NullString]) },
+			`{a,NULL,"NULL",""}`, &Array[sql.NullString]{ns("a"), {}, ns("NULL"), ns("")}},
+		{"Text quoting", func() geometry { return new(Array[sql.NullString]) },
+			`{"a b","c,d","e\"f","g\\h","{i}"}`, &Array[sql.NullString]{ns("a b"), ns("c,d"), ns(`e"f`), ns(`g\h`), ns("{i}")}},
+		{"Int", func() geometry { return new(Array[sql.NullInt64]) },
+			`{1,-2,NULL}`, &Array[sql.NullInt64]{{Int64: 1, Valid: true}, {Int64: -2, Valid: true}, {}}},
+		{"Bool", func() geometry { return new(Array[sql.NullBool]) },
+			`{t,f}`, &Array[sql.NullBool]{{Bool: true, Valid: true}, {Bool: false, Valid: true}}},
+		{"Enum", func() geometry { return new(Array[testMood]) },
+			`{happy,sad}`, &Array[testMood]{"happy", "sad"}},
+		{"JSONB", func() geometry { return new(Array[JSONB[jsonbSample]]) },
+			`{"{\"name\":\"a\",\"age\":1}",NULL}`, &Array[JSONB[jsonbSample]]{NewJSONB(jsonbSample{"a", 1}), {}}},
+		{"Nullable point", func() geometry { return new(Array[NullPoint]) },
+			`{"(1,2)",NULL}`, &Array[NullPoint]{NewNull(Point{1, 2}), {}}},
+		{"Two dimensions", func() geometry { return new(Array[Array[sql.NullInt64]]) },
+			`{{1,2},{3,NULL}}`, &Array[Array[sql.NullInt64]]{
+				{{Int64: 1, Valid: true}, {Int64: 2, Valid: true}},
+				{{Int64: 3, Valid: true}, {}},
+			}},
+		{"Two dimensions text", func() geometry { return new(Array[Array[sql.NullString]]) },
+			`{{"a}",b},{c,"d,e"}}`, &Array[Array[sql.NullString]]{{ns("a}"), ns("b")}, {ns("c"), ns("d,e")}}},
+		{"Two dimensions box", func() geometry { return new(Array[Array[Box]]) },
+			`{{(1,1),(0,0);(2,2),(1,1)}}`, &Array[Array[Box]]{{{Point{1, 1}, Point{0, 0}}, {Point{2, 2}, Point{1, 1}}}}},
+		{"Empty", func() geometry { return new(Array[sql.NullString]) }, `{}`, &Array[sql.NullString]{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.new()
+			if err := a.Scan([]byte(tt.text)); err != nil {
+				t.Fatalf("Scan error: %v", err)
+			}
+			if !reflect.DeepEqual(a, tt.expected) {
+				t.Errorf("Scan mismatch: got %+v, want %+v", a, tt.expected)
+			}
+
+			v, err := a.Value()
+			if err != nil {
+				t.Fatalf("Value error: %v", err)
+			}
+			if v != tt.text {
+				t.Errorf("Value mismatch: got %v, want %v", v, tt.text)
+			}
+		})
+	}
+}
+
+func TestArrayScan(t *testing.T) {
+	var a Array[sql.NullString]
+
+	// dimension decoration and spaces
+	if err := a.Scan(`[0:1]={ x , "y" }`); err != nil || !reflect.DeepEqual(a, Array[sql.NullString]{ns("x"), ns("y")}) {
+		t.Errorf("Scan mismatch: %+v, %v", a, err)
+	}
+
+	if err := a.Scan(nil); err != nil || a != nil {
+		t.Errorf("Scan NULL mismatch: %+v, %v", a, err)
+	}
+	if v, err := a.Value(); v != nil || err != nil {
+		t.Errorf("Value of nil array: got %v, %v", v, err)
+	}
+
+	for _, text := range []string{`{a,b`, `a,b}`, `{"a}`, `{a "b"}`, `{{1},{2}`} {
+		if err := a.Scan(text); !errors.Is(err, ErrWrongArrayType) {
+			t.Errorf("Scan %q: got %v, want %v", text, err, ErrWrongArrayType)
+		}
+	}
+
+	var moods Array[testMood]
+	if err := moods.Scan(`{happy,NULL}`); !errors.Is(err, ErrWrongArrayType) {
+		t.Errorf("Scan NULL to non-null element: got %v, want %v", err, ErrWrongArrayType)
+	}
+}
@@ -0,0 +1,48 @@
+package qdb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/kkkbird/qapp/qcontext"
+)
+
+// BudgetQuerier record the time of context queries to the qcontext.Budget of ctx
+type BudgetQuerier struct {
+	Querier
+	Name string // name recorded to budget, default "db"
+}
+
+// NewBudgetQuerier wrap q to record query time to qcontext.Budget, usage example:
+// ctx, budget := qcontext.WithBudget(ctx)
+// qdb.NewBudgetQuerier(db).QueryRowContext(ctx, sqlstr).Scan(&v)
+// log.Info(budget)
+func NewBudgetQuerier(q Querier) *BudgetQuerier {
+	return &BudgetQuerier{Querier: q, Name: "db"}
+}
+
+func (q *BudgetQuerier) unwrap() Querier {
+	return q.Querier
+}
+
+func (q *BudgetQuerier) rewrap(inner Querier) Querier {
+	return &BudgetQuerier{Querier: inner, Name: q.Name}
+}
+
+// ExecContext implements Querier
+func (q *BudgetQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer qcontext.Track(ctx, q.Name)()
+	return q.Querier.ExecContext(ctx, query, args...)
+}
+
+// QueryContext implements Querier
+func (q *BudgetQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer qcontext.Track(ctx, q.Name)()
+	return q.Querier.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext implements Querier
+func (q *BudgetQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer qcontext.Track(ctx, q.Name)()
+	return q.Querier.QueryRowContext(ctx, query, args...)
+}
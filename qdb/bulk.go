@@ -0,0 +1,388 @@
+package qdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrBulkNoColumns = errors.New("bulk insert needs table and columns")
+)
+
+// BulkMode is how BulkInsert writes rows
+type BulkMode int
+
+// bulk modes
+const (
+	BulkAuto   BulkMode = iota // COPY if the driver is lib/pq, else multi-VALUES INSERT, see BulkInsert
+	BulkCopy                   // always COPY
+	BulkValues                 // always multi-VALUES INSERT
+)
+
+// DefaultBulkChunkSize is the default rows per INSERT of multi-VALUES mode
+const DefaultBulkChunkSize = 1000
+
+// maxBulkParams is the limit of bind parameters of a PostgreSQL statement
+const maxBulkParams = 65535
+
+// bulkUpsertTable is the temp table rows are copied into before upserted
+const bulkUpsertTable = "qdb_bulk_upsert"
+
+var pqPkgPath = reflect.TypeOf(pq.Driver{}).PkgPath()
+
+// BulkOptions is the options of BulkInsert
+type BulkOptions struct {
+	Table     string   // table name, could be qualified by schema, ex "public.points"
+	Columns   []string // columns of each row
+	Mode      BulkMode
+	ChunkSize int // rows per INSERT of multi-VALUES mode, default DefaultBulkChunkSize
+
+	// upsert mode if OnConflict is set: ON CONFLICT (OnConflict) DO UPDATE SET Update = EXCLUDED.Update,
+	// or DO NOTHING if Update is empty
+	OnConflict []string
+	Update     []string
+}
+
+// BulkWriter writes rows of BulkInsert
+type BulkWriter struct {
+	ctx  context.Context
+	q    Querier
+	opts *BulkOptions
+
+	stmt *sql.Stmt // COPY statement, nil in multi-VALUES mode
+
+	chunk  int
+	buf    []interface{}
+	copied int64
+	n      int64
+}
+
+// BulkInsert insert rows written by fn in a transaction of q, see WithTx for how the transaction runs.
+// rows are streamed by COPY if the driver is lib/pq, otherwise they are inserted in chunks by multi-VALUES INSERT.
+// the driver of a caller-supplied *sql.Tx is detected by reflect, set Mode explicitly to not depend on it.
+// upsert mode COPY rows into the temp table qdb_bulk_upsert then INSERT ... ON CONFLICT from it, so upserts could
+// not be nested in the same session, and rows with duplicate conflict keys in one BulkInsert fail with
+// "ON CONFLICT DO UPDATE command cannot affect row a second time".
+// qdb types like Point, PointArray and JSONB[T] could be values. the number of rows inserted or updated is returned,
+// usage example:
+//
+//	n, err := qdb.BulkInsert(ctx, db, &qdb.BulkOptions{Table: "points", Columns: []string{"id", "loc"}}, func(w *qdb.BulkWriter) error {
+//		for _, p := range points {
+//			if err := w.Write(p.ID, p.Loc); err != nil {
+//				return err
+//			}
+//		}
+//		return nil
+//	})
+func BulkInsert(ctx context.Context, q Querier, opts *BulkOptions, fn func(w *BulkWriter) error) (int64, error) {
+	if opts == nil || opts.Table == "" || len(opts.Columns) == 0 {
+		return 0, ErrBulkNoColumns
+	}
+
+	mode := bulkMode(q, opts.Mode)
+
+	var n int64
+	err := WithTx(ctx, q, nil, func(tx Querier) error {
+		w, err := newBulkWriter(ctx, tx, opts, mode)
+		if err != nil {
+			return err
+		}
+
+		if err = fn(w); err != nil {
+			w.close()
+			return err
+		}
+
+		if err = w.finish(); err != nil {
+			return err
+		}
+		n = w.n
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func newBulkWriter(ctx context.Context, q Querier, opts *BulkOptions, mode BulkMode) (*BulkWriter, error) {
+	w := &BulkWriter{ctx: ctx, q: q, opts: opts}
+
+	if mode == BulkValues {
+		w.chunk = opts.ChunkSize
+		if w.chunk <= 0 {
+			w.chunk = DefaultBulkChunkSize
+		}
+		w.chunk = min(w.chunk, maxBulkParams/len(opts.Columns))
+		return w, nil
+	}
+
+	table := quoteTable(opts.Table)
+	if opts.isUpsert() {
+		_, err := q.ExecContext(ctx, fmt.Sprintf("CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA",
+			bulkUpsertTable, quoteColumns(opts.Columns), table))
+		if err != nil {
+			return nil, err
+		}
+		table = bulkUpsertTable
+	}
+
+	// lib/pq runs a prepared COPY statement in copy mode, each Exec sends a row
+	stmt, err := unwrapTx(q).PrepareContext(ctx, fmt.Sprintf("COPY %s (%s) FROM STDIN", table, quoteColumns(opts.Columns)))
+	if err != nil {
+		return nil, err
+	}
+	w.stmt = stmt
+	return w, nil
+}
+
+func (o *BulkOptions) isUpsert() bool {
+	return len(o.OnConflict) > 0
+}
+
+// Write write a row, values are in the order of BulkOptions.Columns
+func (w *BulkWriter) Write(values ...interface{}) error {
+	if len(values) != len(w.opts.Columns) {
+		return fmt.Errorf("bulk insert %s: %d values for %d columns", w.opts.Table, len(values), len(w.opts.Columns))
+	}
+
+	if w.stmt != nil {
+		args := make([]interface{}, len(values))
+		for i, v := range values {
+			var err error
+			if args[i], err = copyValue(v); err != nil {
+				return fmt.Errorf("bulk insert %s column %s: %w", w.opts.Table, w.opts.Columns[i], err)
+			}
+		}
+
+		if _, err := w.stmt.ExecContext(w.ctx, args...); err != nil {
+			return err
+		}
+		w.copied++
+		return nil
+	}
+
+	w.buf = append(w.buf, values...)
+	if len(w.buf) >= w.chunk*len(w.opts.Columns) {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush insert buffered rows by a multi-VALUES INSERT
+func (w *BulkWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	cols := len(w.opts.Columns)
+
+	var b strings.Builder
+	b.WriteString("INSERT INTO " + quoteTable(w.opts.Table) + " (" + quoteColumns(w.opts.Columns) + ") VALUES ")
+	for i := 0; i < len(w.buf); i += cols {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for j := 0; j < cols; j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("$" + strconv.Itoa(i+j+1))
+		}
+		b.WriteByte(')')
+	}
+	b.WriteString(w.opts.conflictClause())
+
+	result, err := w.q.ExecContext(w.ctx, b.String(), w.buf...)
+	if err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	w.n += n
+	return nil
+}
+
+// finish flush the rest rows, and upsert copied rows from the temp table
+func (w *BulkWriter) finish() error {
+	if w.stmt == nil {
+		return w.flush()
+	}
+
+	// COPY is done by an Exec without args
+	_, err := w.stmt.ExecContext(w.ctx)
+	if closeErr := w.stmt.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if !w.opts.isUpsert() {
+		w.n = w.copied
+		return nil
+	}
+
+	cols := quoteColumns(w.opts.Columns)
+	result, err := w.q.ExecContext(w.ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s%s",
+		quoteTable(w.opts.Table), cols, cols, bulkUpsertTable, w.opts.conflictClause()))
+	if err != nil {
+		return err
+	}
+	if w.n, err = result.RowsAffected(); err != nil {
+		return err
+	}
+
+	_, err = w.q.ExecContext(w.ctx, "DROP TABLE "+bulkUpsertTable)
+	return err
+}
+
+func (w *BulkWriter) close() {
+	if w.stmt != nil {
+		w.stmt.Close()
+	}
+}
+
+func (o *BulkOptions) conflictClause() string {
+	if !o.isUpsert() {
+		return ""
+	}
+
+	clause := " ON CONFLICT (" + quoteColumns(o.OnConflict) + ")"
+	if len(o.Update) == 0 {
+		return clause + " DO NOTHING"
+	}
+
+	sets := make([]string, len(o.Update))
+	for i, col := range o.Update {
+		sets[i] = pq.QuoteIdentifier(col) + " = EXCLUDED." + pq.QuoteIdentifier(col)
+	}
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// unwrapTx return the transaction of WithTx under wrappers of q
+func unwrapTx(q Querier) *txQuerier {
+	for {
+		w, ok := q.(wrappedQuerier)
+		if !ok {
+			return q.(*txQuerier)
+		}
+		q = w.unwrap()
+	}
+}
+
+// bulkMode resolve BulkAuto to BulkCopy if the driver of q is lib/pq, else BulkValues. the driver of a *sql.DB
+// is checked by db.Driver(), while a caller-supplied *sql.Tx falls back to reflect
+func bulkMode(q Querier, mode BulkMode) BulkMode {
+	if mode != BulkAuto {
+		return mode
+	}
+
+	for {
+		w, ok := q.(wrappedQuerier)
+		if !ok {
+			break
+		}
+		q = w.unwrap()
+	}
+
+	var t reflect.Type
+	switch q := q.(type) {
+	case *sql.DB:
+		t = reflect.TypeOf(q.Driver())
+	case *sql.Tx:
+		t = txDriverConnType(q)
+	case *txQuerier:
+		t = txDriverConnType(q.Tx)
+	}
+
+	if t == nil {
+		log.Warnf("bulk insert could not detect the driver of %T, use multi-VALUES INSERT, set BulkOptions.Mode to COPY", q)
+		return BulkValues
+	}
+	if isPQType(t) {
+		return BulkCopy
+	}
+	return BulkValues
+}
+
+// isPQType check if t is a type of lib/pq, which supports COPY
+func isPQType(t reflect.Type) bool {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t != nil && t.PkgPath() == pqPkgPath
+}
+
+// txDriverConnType return the type of the driver conn of tx, nil if unknown. database/sql doesn't expose it,
+// so it is read from the unexported fields tx.dc.ci by reflect like qdebugserver reads sql.DB, and it is nil
+// if the fields are renamed
+func txDriverConnType(tx *sql.Tx) reflect.Type {
+	dc := reflect.ValueOf(tx).Elem().FieldByName("dc")
+	if !dc.IsValid() || dc.Kind() != reflect.Ptr || dc.IsNil() {
+		return nil
+	}
+
+	ci := dc.Elem().FieldByName("ci")
+	if !ci.IsValid() || ci.Kind() != reflect.Interface || ci.IsNil() {
+		return nil
+	}
+	return ci.Elem().Type()
+}
+
+// textValuer is a Valuer whose []byte value is text, ex JSONB[T], it is copied as string but not bytea
+type textValuer interface {
+	driver.Valuer
+	isTextValue()
+}
+
+// copyValue convert v to a value pq could COPY, []byte of a textValuer is converted to string,
+// other []byte is copied as bytea
+func copyValue(v interface{}) (interface{}, error) {
+	_, isText := v.(textValuer)
+
+	dv, err := driver.DefaultParameterConverter.ConvertValue(v)
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := dv.([]byte); ok && isText {
+		return string(b), nil
+	}
+	return dv, nil
+}
+
+func splitTable(table string) (schema, name string) {
+	if i := strings.LastIndexByte(table, '.'); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+func quoteTable(table string) string {
+	schema, name := splitTable(table)
+	if schema == "" {
+		return pq.QuoteIdentifier(name)
+	}
+	return pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(name)
+}
+
+func quoteColumns(cols []string) string {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = pq.QuoteIdentifier(col)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,202 @@
+package qdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestBulkInsertValues(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	var args [][]driver.NamedValue
+	f.onExec = func(query string, a []driver.NamedValue) (driver.Result, error) {
+		if len(a) > 0 {
+			args = append(args, a)
+		}
+		return driver.RowsAffected(len(a) / 2), nil
+	}
+
+	opts := &BulkOptions{
+		Table:      "public.points",
+		Columns:    []string{"id", "loc"},
+		ChunkSize:  2,
+		OnConflict: []string{"id"},
+		Update:     []string{"loc"},
+	}
+	n, err := BulkInsert(context.Background(), db, opts, func(w *BulkWriter) error {
+		for i := int64(1); i <= 3; i++ {
+			if err := w.Write(i, Point{float64(i), 0}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("BulkInsert() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("BulkInsert() = %d, expected 3", n)
+	}
+
+	const conflict = ` ON CONFLICT ("id") DO UPDATE SET "loc" = EXCLUDED."loc"`
+	expected := []string{
+		"BEGIN",
+		`INSERT INTO "public"."points" ("id", "loc") VALUES ($1, $2), ($3, $4)` + conflict,
+		`INSERT INTO "public"."points" ("id", "loc") VALUES ($1, $2)` + conflict,
+		"COMMIT",
+	}
+	if stmts := f.statements(); !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("BulkInsert() statements = %q, expected %q", stmts, expected)
+	}
+	if len(args) != 2 || args[0][3].Value != "(2,0)" || args[1][0].Value != int64(3) {
+		t.Errorf("BulkInsert() args = %v", args)
+	}
+
+	if _, err = BulkInsert(context.Background(), db, opts, func(w *BulkWriter) error {
+		return w.Write(1)
+	}); err == nil {
+		t.Error("BulkInsert() with wrong number of values expected error")
+	}
+	if stmts := f.statements(); !reflect.DeepEqual(stmts, []string{"BEGIN", "ROLLBACK"}) {
+		t.Errorf("BulkInsert() statements = %q", stmts)
+	}
+}
+
+func TestBulkInsertCopy(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	var rows [][]driver.NamedValue
+	f.onExec = func(query string, a []driver.NamedValue) (driver.Result, error) {
+		if len(a) > 0 {
+			rows = append(rows, a)
+		}
+		return driver.RowsAffected(2), nil
+	}
+
+	opts := &BulkOptions{
+		Table:      "points",
+		Columns:    []string{"id", "locs", "extra"},
+		Mode:       BulkCopy,
+		OnConflict: []string{"id"},
+	}
+	n, err := BulkInsert(context.Background(), db, opts, func(w *BulkWriter) error {
+		if err := w.Write(1, PointArray{{1, 2}}, NewJSONB(jsonbSample{Name: "tom"})); err != nil {
+			return err
+		}
+		return w.Write(2, nil, JSONB[jsonbSample]{})
+	})
+	if err != nil {
+		t.Fatalf("BulkInsert() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("BulkInsert() = %d, expected 2", n)
+	}
+
+	const copyStmt = `COPY qdb_bulk_upsert ("id", "locs", "extra") FROM STDIN`
+	expected := []string{
+		"BEGIN",
+		`CREATE TEMP TABLE qdb_bulk_upsert ON COMMIT DROP AS SELECT "id", "locs", "extra" FROM "points" WITH NO DATA`,
+		copyStmt, copyStmt, copyStmt,
+		`INSERT INTO "points" ("id", "locs", "extra") SELECT "id", "locs", "extra" FROM qdb_bulk_upsert ON CONFLICT ("id") DO NOTHING`,
+		"DROP TABLE qdb_bulk_upsert",
+		"COMMIT",
+	}
+	if stmts := f.statements(); !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("BulkInsert() statements = %q, expected %q", stmts, expected)
+	}
+
+	// jsonb is copied as text but not bytea
+	expectedRows := [][]interface{}{
+		{int64(1), `{"(1,2)"}`, `{"name":"tom","age":0}`},
+		{int64(2), nil, nil},
+	}
+	if len(rows) != len(expectedRows) {
+		t.Fatalf("BulkInsert() rows = %v", rows)
+	}
+	for i, row := range rows {
+		for j, v := range row {
+			if v.Value != expectedRows[i][j] {
+				t.Errorf("BulkInsert() row %d column %d = %#v, expected %#v", i, j, v.Value, expectedRows[i][j])
+			}
+		}
+	}
+}
+
+func TestBulkMode(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	pqDB, err := sql.Open("postgres", "host=localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pqDB.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	if got := txDriverConnType(tx); got != reflect.TypeOf(&fakeConn{}) {
+		t.Errorf("txDriverConnType() = %v, expected *fakeConn", got)
+	}
+
+	tests := []struct {
+		name     string
+		q        Querier
+		mode     BulkMode
+		expected BulkMode
+	}{
+		{"Fake DB", db, BulkAuto, BulkValues},
+		{"PQ DB", pqDB, BulkAuto, BulkCopy},
+		{"Wrapped PQ DB", Instrument(pqDB, &InstrumentOptions{Name: "bulk"}), BulkAuto, BulkCopy},
+		{"Fake Tx", tx, BulkAuto, BulkValues},
+		{"Explicit mode", db, BulkCopy, BulkCopy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bulkMode(tt.q, tt.mode); got != tt.expected {
+				t.Errorf("bulkMode() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// byteaValue is a Valuer of a bytea column
+type byteaValue []byte
+
+func (b byteaValue) Value() (driver.Value, error) { return []byte(b), nil }
+
+func TestCopyValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       interface{}
+		expected interface{}
+	}{
+		{"Int", 1, int64(1)},
+		{"Bytes", []byte{0, 1}, []byte{0, 1}},
+		{"Bytea valuer", byteaValue{0, 1}, []byte{0, 1}},
+		{"JSONB", NewJSONB(map[string]int{"a": 1}), `{"a":1}`},
+		{"Deprecated JsonB", WrapJSONB(&map[string]int{"a": 1}), `{"a":1}`},
+		{"Point", Point{1, 2}, "(1,2)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := copyValue(tt.in)
+			if err != nil {
+				t.Fatalf("copyValue() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("copyValue() = %#v, expected %#v", got, tt.expected)
+			}
+		})
+	}
+}
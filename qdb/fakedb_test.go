@@ -0,0 +1,137 @@
+package qdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDB is a database/sql driver recording statements, results are from onExec and onQuery
+type fakeDB struct {
+	mu    sync.Mutex
+	stmts []string
+
+	onExec  func(query string, args []driver.NamedValue) (driver.Result, error)
+	onQuery func(query string, args []driver.NamedValue) (driver.Rows, error)
+	onPing  func() error
+}
+
+func newFakeDB() (*fakeDB, *sql.DB) {
+	f := &fakeDB{}
+	return f, sql.OpenDB(f)
+}
+
+func (f *fakeDB) record(stmt string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stmts = append(f.stmts, stmt)
+}
+
+// statements return recorded statements and reset them
+func (f *fakeDB) statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stmts := f.stmts
+	f.stmts = nil
+	return stmts
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return f }
+func (f *fakeDB) Open(name string) (driver.Conn, error)        { return &fakeConn{f}, nil }
+
+type fakeConn struct {
+	f *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{c, query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+	if c.f.onPing != nil {
+		return c.f.onPing()
+	}
+	return nil
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.ExecContext(ctx, "BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	_, err := c.ExecContext(context.Background(), "COMMIT", nil)
+	return err
+}
+
+func (c *fakeConn) Rollback() error {
+	_, err := c.ExecContext(context.Background(), "ROLLBACK", nil)
+	return err
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.f.record(query)
+	if c.f.onExec != nil {
+		return c.f.onExec(query, args)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.f.record(query)
+	if c.f.onQuery != nil {
+		return c.f.onQuery(query, args)
+	}
+	return &fakeRows{}, nil
+}
+
+// fakeRows is driver.Rows of fixed values
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func newFakeRows(columns string, values ...[]driver.Value) *fakeRows {
+	return &fakeRows{columns: strings.Split(columns, ","), values: values}
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// fakeStmt is a prepared statement, each Exec is recorded as its query
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return s.c.ExecContext(context.Background(), s.query, named)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
@@ -0,0 +1,261 @@
+package qdb
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrWrongGeometryType = errors.New("geometry scan failed")
+	ErrNullGeometry      = errors.New("geometry is NULL, use qdb.Null")
+)
+
+// Box is PostgreSQL box, PostgreSQL reorders the corners so High is the upper right one
+type Box struct {
+	High Point
+	Low  Point
+}
+
+// Lseg is PostgreSQL line segment
+type Lseg struct {
+	P1 Point
+	P2 Point
+}
+
+// Line is PostgreSQL infinite line Ax + By + C = 0
+type Line struct {
+	A float64
+	B float64
+	C float64
+}
+
+// Path is PostgreSQL path, open or closed
+type Path struct {
+	Points []Point
+	Closed bool
+}
+
+// Polygon is PostgreSQL polygon
+type Polygon []Point
+
+// Circle is PostgreSQL circle
+type Circle struct {
+	Center Point
+	Radius float64
+}
+
+// nullable geometric types
+type (
+	NullPoint   = Null[Point]
+	NullBox     = Null[Box]
+	NullLseg    = Null[Lseg]
+	NullLine    = Null[Line]
+	NullPath    = Null[Path]
+	NullPolygon = Null[Polygon]
+	NullCircle  = Null[Circle]
+)
+
+// formatFloat format f with the fewest digits to keep its precision
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// scanFloats parse the numbers of a geometric value, n < 0 means an even number of at least 2.
+// the trimmed text is returned to check the open/closed marks
+func scanFloats(src interface{}, typ string, n int) ([]float64, string, error) {
+	var s string
+
+	switch src := src.(type) {
+	case []byte:
+		s = string(src)
+	case string:
+		s = src
+	case nil:
+		return nil, "", fmt.Errorf("%w: %s", ErrNullGeometry, typ)
+	default:
+		return nil, "", fmt.Errorf("%w: %s from %T", ErrWrongGeometryType, typ, src)
+	}
+
+	s = strings.TrimSpace(s)
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune("()[]<>{}, \t\"", r)
+	})
+
+	if (n >= 0 && len(fields) != n) || (n < 0 && (len(fields) < 2 || len(fields)%2 != 0)) {
+		return nil, "", fmt.Errorf("%w: %s %q", ErrWrongGeometryType, typ, s)
+	}
+
+	floats := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %s %q", ErrWrongGeometryType, typ, s)
+		}
+		floats[i] = v
+	}
+	return floats, s, nil
+}
+
+func toPoints(floats []float64) []Point {
+	points := make([]Point, len(floats)/2)
+	for i := range points {
+		points[i] = Point{floats[2*i], floats[2*i+1]}
+	}
+	return points
+}
+
+func formatPoints(points []Point) string {
+	parts := make([]string, len(points))
+	for i, pt := range points {
+		parts[i] = pt.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Scan implements the Scanner interface.
+func (b *Box) Scan(src interface{}) error {
+	f, _, err := scanFloats(src, "box", 4)
+	if err != nil {
+		return err
+	}
+	b.High, b.Low = Point{f[0], f[1]}, Point{f[2], f[3]}
+	return nil
+}
+
+// ArrayDelimiter implements ArrayDelimiter, box array is separated by ';' in PostgreSQL
+func (Box) ArrayDelimiter() byte {
+	return ';'
+}
+
+// Value implements the driver Valuer interface.
+func (b Box) Value() (driver.Value, error) {
+	return b.String(), nil
+}
+
+// String return the text format of box, ex "(1,1),(0,0)"
+func (b Box) String() string {
+	return b.High.String() + "," + b.Low.String()
+}
+
+// Scan implements the Scanner interface.
+func (l *Lseg) Scan(src interface{}) error {
+	f, _, err := scanFloats(src, "lseg", 4)
+	if err != nil {
+		return err
+	}
+	l.P1, l.P2 = Point{f[0], f[1]}, Point{f[2], f[3]}
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (l Lseg) Value() (driver.Value, error) {
+	return l.String(), nil
+}
+
+// String return the text format of lseg, ex "[(0,0),(1,1)]"
+func (l Lseg) String() string {
+	return "[" + l.P1.String() + "," + l.P2.String() + "]"
+}
+
+// Scan implements the Scanner interface.
+func (l *Line) Scan(src interface{}) error {
+	f, _, err := scanFloats(src, "line", 3)
+	if err != nil {
+		return err
+	}
+	l.A, l.B, l.C = f[0], f[1], f[2]
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (l Line) Value() (driver.Value, error) {
+	return l.String(), nil
+}
+
+// String return the text format of line, ex "{1,-1,0}"
+func (l Line) String() string {
+	return "{" + formatFloat(l.A) + "," + formatFloat(l.B) + "," + formatFloat(l.C) + "}"
+}
+
+// Scan implements the Scanner interface.
+func (p *Path) Scan(src interface{}) error {
+	f, s, err := scanFloats(src, "path", -1)
+	if err != nil {
+		return err
+	}
+	p.Points, p.Closed = toPoints(f), !strings.HasPrefix(s, "[")
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (p Path) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+// String return the text format of path, ex "[(0,0),(1,1)]" for open path and "((0,0),(1,1))" for closed path
+func (p Path) String() string {
+	if p.Closed {
+		return "(" + formatPoints(p.Points) + ")"
+	}
+	return "[" + formatPoints(p.Points) + "]"
+}
+
+// Scan implements the Scanner interface.
+func (p *Polygon) Scan(src interface{}) error {
+	f, _, err := scanFloats(src, "polygon", -1)
+	if err != nil {
+		return err
+	}
+	*p = toPoints(f)
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (p Polygon) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+// String return the text format of polygon, ex "((0,0),(1,1),(1,0))"
+func (p Polygon) String() string {
+	return "(" + formatPoints(p) + ")"
+}
+
+// Scan implements the Scanner interface.
+func (c *Circle) Scan(src interface{}) error {
+	f, _, err := scanFloats(src, "circle", 3)
+	if err != nil {
+		return err
+	}
+	c.Center, c.Radius = Point{f[0], f[1]}, f[2]
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (c Circle) Value() (driver.Value, error) {
+	return c.String(), nil
+}
+
+// String return the text format of circle, ex "<(0,0),1>"
+func (c Circle) String() string {
+	return "<" + c.Center.String() + "," + formatFloat(c.Radius) + ">"
+}
+
+// geometric arrays
+type (
+	BoxArray     = Array[Box]
+	LsegArray    = Array[Lseg]
+	LineArray    = Array[Line]
+	PathArray    = Array[Path]
+	PolygonArray = Array[Polygon]
+	CircleArray  = Array[Circle]
+)
@@ -0,0 +1,123 @@
+package qdb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type geometry interface {
+	sql.Scanner
+	driver.Valuer
+}
+
+func TestGeometryRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		new      func() geometry
+		text     string // as output by PostgreSQL
+		expected interface{}
+	}{
+		{"Point", func() geometry { return new(Point) }, "(1.5,-2)", &Point{1.5, -2}},
+		{"Point precision", func() geometry { return new(Point) }, "(0.123456789012345,1e+21)", &Point{0.123456789012345, 1e21}},
+		{"Box", func() geometry { return new(Box) }, "(2,2),(0,0)", &Box{Point{2, 2}, Point{0, 0}}},
+		{"Lseg", func() geometry { return new(Lseg) }, "[(0,0),(1,1.25)]", &Lseg{Point{0, 0}, Point{1, 1.25}}},
+		{"Line", func() geometry { return new(Line) }, "{1,-1,0}", &Line{1, -1, 0}},
+		{"Open path", func() geometry { return new(Path) }, "[(0,0),(1,1),(2,0)]", &Path{Points: []Point{{0, 0}, {1, 1}, {2, 0}}}},
+		{"Closed path", func() geometry { return new(Path) }, "((0,0),(1,1))", &Path{Points: []Point{{0, 0}, {1, 1}}, Closed: true}},
+		{"Single point path", func() geometry { return new(Path) }, "((3,4))", &Path{Points: []Point{{3, 4}}, Closed: true}},
+		{"Polygon", func() geometry { return new(Polygon) }, "((0,0),(1,1),(1,0))", &Polygon{{0, 0}, {1, 1}, {1, 0}}},
+		{"Circle", func() geometry { return new(Circle) }, "<(1,2),0.5>", &Circle{Point{1, 2}, 0.5}},
+		{"Infinity", func() geometry { return new(Line) }, "{-Infinity,Infinity,0}", nil},
+		{"Point array", func() geometry { return new(PointArray) }, `{"(1,2)","(3.5,4)"}`, &PointArray{{1, 2}, {3.5, 4}}},
+		{"Box array", func() geometry { return new(BoxArray) }, `{(1,1),(0,0);(3,3),(2,2)}`, &BoxArray{{Point{1, 1}, Point{0, 0}}, {Point{3, 3}, Point{2, 2}}}},
+		{"Lseg array", func() geometry { return new(LsegArray) }, `{"[(0,0),(1,1)]"}`, &LsegArray{{Point{0, 0}, Point{1, 1}}}},
+		{"Line array", func() geometry { return new(LineArray) }, `{"{1,2,3}"}`, &LineArray{{1, 2, 3}}},
+		{"Path array", func() geometry { return new(PathArray) }, `{"[(0,0),(1,1)]","((0,0),(1,1))"}`, &PathArray{{Points: []Point{{0, 0}, {1, 1}}}, {Points: []Point{{0, 0}, {1, 1}}, Closed: true}}},
+		{"Polygon array", func() geometry { return new(PolygonArray) }, `{"((0,0),(1,1),(1,0))"}`, &PolygonArray{{{0, 0}, {1, 1}, {1, 0}}}},
+		{"Circle array", func() geometry { return new(CircleArray) }, `{"<(0,0),1>","<(1,1),2>"}`, &CircleArray{{Point{0, 0}, 1}, {Point{1, 1}, 2}}},
+		{"Empty array", func() geometry { return new(CircleArray) }, `{}`, &CircleArray{}},
+		{"Null box", func() geometry { return new(NullBox) }, "(1,1),(0,0)", &NullBox{Box{Point{1, 1}, Point{0, 0}}, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := tt.new()
+			if err := g.Scan([]byte(tt.text)); err != nil {
+				t.Fatalf("Scan error: %v", err)
+			}
+			if tt.expected != nil && !reflect.DeepEqual(g, tt.expected) {
+				t.Errorf("Scan mismatch: got %+v, want %+v", g, tt.expected)
+			}
+
+			v, err := g.Value()
+			if err != nil {
+				t.Fatalf("Value error: %v", err)
+			}
+			if v != tt.text {
+				t.Errorf("Value mismatch: got %v, want %v", v, tt.text)
+			}
+
+			// scan the value back
+			g2 := tt.new()
+			if err = g2.Scan(v); err != nil {
+				t.Fatalf("Scan value error: %v", err)
+			}
+			if !reflect.DeepEqual(g, g2) {
+				t.Errorf("Round trip mismatch: got %+v, want %+v", g2, g)
+			}
+		})
+	}
+}
+
+func TestGeometryNull(t *testing.T) {
+	var c Circle
+	if err := c.Scan(nil); !errors.Is(err, ErrNullGeometry) {
+		t.Errorf("Scan NULL: got %v, want %v", err, ErrNullGeometry)
+	}
+
+	pt := Point{1, 1}
+	if err := pt.Scan(nil); !errors.Is(err, ErrNullGeometry) || pt != (Point{1, 1}) {
+		t.Errorf("Point Scan NULL: got %v, %+v, want %v", err, pt, ErrNullGeometry)
+	}
+
+	nc := NewNull(Circle{Point{1, 1}, 1})
+	if err := nc.Scan(nil); err != nil || nc.Valid || nc.V != (Circle{}) {
+		t.Errorf("Scan NULL mismatch: %+v, %v", nc, err)
+	}
+	if v, err := nc.Value(); v != nil || err != nil {
+		t.Errorf("Value of NULL: got %v, %v", v, err)
+	}
+
+	var a PathArray
+	if err := a.Scan(nil); err != nil || a != nil {
+		t.Errorf("Scan NULL array mismatch: %+v, %v", a, err)
+	}
+	if v, err := a.Value(); v != nil || err != nil {
+		t.Errorf("Value of nil array: got %v, %v", v, err)
+	}
+	if err := a.Scan(`{NULL}`); !errors.Is(err, ErrWrongArrayType) {
+		t.Errorf("Scan NULL element: got %v, want %v", err, ErrWrongArrayType)
+	}
+}
+
+func TestGeometryScanError(t *testing.T) {
+	tests := []struct {
+		g    geometry
+		text string
+	}{
+		{new(Box), "(1,1)"},
+		{new(Line), "{1,2}"},
+		{new(Path), "[(0,0),(1)]"},
+		{new(Polygon), "()"},
+		{new(Circle), "<(0,0),x>"},
+	}
+
+	for _, tt := range tests {
+		if err := tt.g.Scan(tt.text); !errors.Is(err, ErrWrongGeometryType) {
+			t.Errorf("Scan %T %q: got %v, want %v", tt.g, tt.text, err, ErrWrongGeometryType)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package qdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var initFakeDB = &fakeDB{}
+
+func init() {
+	sql.Register("qdbfake", initFakeDB)
+}
+
+func TestInitFunc(t *testing.T) {
+	viper.Set("db.test.driver", "qdbfake")
+	viper.Set("db.test.dsn", "fake")
+	viper.Set("db.test.max_open", 5)
+	viper.Set("db.test.ping_interval", time.Millisecond)
+	defer viper.Reset()
+
+	pings := 0
+	initFakeDB.onPing = func() error {
+		if pings++; pings < 3 {
+			return driver.ErrBadConn
+		}
+		return nil
+	}
+	defer func() { initFakeDB.onPing = nil }()
+
+	initFunc := InitFunc("test")
+
+	clean, err := initFunc(context.Background())
+	if err != nil {
+		t.Fatalf("Init error: %v", err)
+	}
+
+	db := DB("test")
+	if db == nil {
+		t.Fatalf("DB should be opened")
+	}
+	if got := db.Stats().MaxOpenConnections; got != 5 {
+		t.Errorf("MaxOpenConnections mismatch: got %d", got)
+	}
+	if pings != 3 {
+		t.Errorf("Ping should be retried: %d pings", pings)
+	}
+
+	clean(context.Background())
+	if DB("test") != nil {
+		t.Errorf("DB should be removed on clean")
+	}
+	if err = db.Ping(); err == nil {
+		t.Errorf("DB should be closed on clean")
+	}
+}
+
+func TestInitFuncTwice(t *testing.T) {
+	viper.Set("db.twice.driver", "qdbfake")
+	viper.Set("db.twice.dsn", "fake")
+	defer viper.Reset()
+
+	for i, maxOpen := range []int{3, 7} {
+		viper.Set("db.twice.max_open", maxOpen)
+
+		// InitFunc publishes "db.twice" to expvar each time, it should not panic
+		clean, err := InitFunc("twice")(context.Background())
+		if err != nil {
+			t.Fatalf("Init %d error: %v", i, err)
+		}
+		defer clean(context.Background())
+	}
+
+	v := expvar.Get("db.twice")
+	if v == nil || !strings.Contains(v.String(), `"MaxOpen":7`) {
+		t.Errorf("Published stats should be of the latest db: %v", v)
+	}
+}
+
+func TestInitFuncError(t *testing.T) {
+	viper.Set("db.bad.driver", "qdbfake")
+	viper.Set("db.bad.ping_retries", 1)
+	viper.Set("db.bad.ping_interval", time.Millisecond)
+	defer viper.Reset()
+
+	initFunc := InitFunc("bad")
+
+	if _, err := initFunc(context.Background()); err == nil {
+		t.Errorf("Init without dsn should fail")
+	}
+
+	viper.Set("db.bad.dsn", "fake")
+	errPing := errors.New("connection refused")
+	initFakeDB.onPing = func() error { return errPing }
+	defer func() { initFakeDB.onPing = nil }()
+
+	if _, err := initFunc(context.Background()); !errors.Is(err, errPing) {
+		t.Errorf("Err mismatch: got %v, want %v", err, errPing)
+	}
+	if DB("bad") != nil {
+		t.Errorf("DB should not be registered on error")
+	}
+}
@@ -0,0 +1,323 @@
+package qdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kkkbird/qapp/qclock"
+	"github.com/kkkbird/qapp/qdebugserver"
+	"github.com/sirupsen/logrus"
+)
+
+// default instrument options
+const (
+	DefaultSlowThreshold = 200 * time.Millisecond
+	DefaultSlowTopN      = 10
+)
+
+// LatencyBuckets is the upper bounds of query latency histograms, the last bucket is +Inf
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// InstrumentOptions is the options of Instrument
+type InstrumentOptions struct {
+	Name          string        // name of stats in debug server, default "db"
+	SlowThreshold time.Duration // queries slower than it are logged, default DefaultSlowThreshold, <0 disable
+	TopN          int           // number of slowest statements shown in debug index, default DefaultSlowTopN
+}
+
+// LatencyBucket is a bucket of latency histogram
+type LatencyBucket struct {
+	Le    string `json:"le"` // upper bound, ex "10ms" or "+Inf"
+	Count int64  `json:"count"`
+}
+
+// QueryStat is the stats of a statement, durations are in nanoseconds in json
+type QueryStat struct {
+	Query     string          `json:"query"`
+	Count     int64           `json:"count"`
+	Errors    int64           `json:"errors"`
+	Total     time.Duration   `json:"total"`
+	Max       time.Duration   `json:"max"`
+	Histogram []LatencyBucket `json:"histogram"`
+}
+
+// Avg return the average latency
+func (s QueryStat) Avg() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// QueryStats is the stats of statements of instrumented queriers with the same name
+type QueryStats struct {
+	mu    sync.Mutex
+	stats map[string]*queryStat
+}
+
+type queryStat struct {
+	count, errors int64
+	total, max    time.Duration
+	buckets       []int64
+}
+
+func (s *QueryStats) add(query string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.stats[query]
+	if !ok {
+		st = &queryStat{buckets: make([]int64, len(LatencyBuckets)+1)}
+		s.stats[query] = st
+	}
+
+	st.count++
+	if err != nil {
+		st.errors++
+	}
+	st.total += d
+	if d > st.max {
+		st.max = d
+	}
+	st.buckets[sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })]++
+}
+
+// List return stats of all statements ordered by count
+func (s *QueryStats) List() []QueryStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]QueryStat, 0, len(s.stats))
+	for query, st := range s.stats {
+		hist := make([]LatencyBucket, len(st.buckets))
+		for i, n := range st.buckets {
+			hist[i].Count = n
+			if i < len(LatencyBuckets) {
+				hist[i].Le = LatencyBuckets[i].String()
+			} else {
+				hist[i].Le = "+Inf"
+			}
+		}
+
+		list = append(list, QueryStat{
+			Query:     query,
+			Count:     st.count,
+			Errors:    st.errors,
+			Total:     st.total,
+			Max:       st.max,
+			Histogram: hist,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Query < list[j].Query
+	})
+	return list
+}
+
+// Slowest return n statements with the largest max latency
+func (s *QueryStats) Slowest(n int) []QueryStat {
+	list := s.List()
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Max > list[j].Max })
+
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// Reset clear all stats
+func (s *QueryStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats = make(map[string]*queryStat)
+}
+
+var (
+	queryStatsMu sync.Mutex
+	queryStats   = make(map[string]*QueryStats)
+)
+
+// GetQueryStats return the stats of instrumented queriers with name, nil if none
+func GetQueryStats(name string) *QueryStats {
+	queryStatsMu.Lock()
+	defer queryStatsMu.Unlock()
+
+	return queryStats[name]
+}
+
+// getOrAddQueryStats return the stats of name, it is published to debug server when created
+func getOrAddQueryStats(name string, topN int) *QueryStats {
+	queryStatsMu.Lock()
+	defer queryStatsMu.Unlock()
+
+	if s, ok := queryStats[name]; ok {
+		return s
+	}
+
+	s := &QueryStats{stats: make(map[string]*queryStat)}
+	queryStats[name] = s
+
+	qdebugserver.AddParam("sql."+name, func() interface{} { return s.List() })
+	qdebugserver.AddIndexList(fmt.Sprintf("Slow queries of %s", name), func() []string {
+		slowest := s.Slowest(topN)
+		items := make([]string, len(slowest))
+		for i, st := range slowest {
+			items[i] = fmt.Sprintf("max %s, avg %s, count %d, errors %d: %s", st.Max, st.Avg(), st.Count, st.Errors, st.Query)
+		}
+		return items
+	})
+
+	return s
+}
+
+// InstrumentedQuerier time queries of Querier, log slow queries and keep stats
+type InstrumentedQuerier struct {
+	Querier
+	name          string
+	slowThreshold time.Duration
+	stats         *QueryStats
+}
+
+// Instrument wrap q to time every query, queries slower than opts.SlowThreshold are logged with args redacted,
+// and stats of each statement are published to debug server as "sql.<name>", usage example:
+//
+//	db = qdb.Instrument(sqlDB, &qdb.InstrumentOptions{Name: "main"})
+//
+// queriers with the same name share stats
+func Instrument(q Querier, opts *InstrumentOptions) *InstrumentedQuerier {
+	o := InstrumentOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Name == "" {
+		o.Name = "db"
+	}
+	if o.SlowThreshold == 0 {
+		o.SlowThreshold = DefaultSlowThreshold
+	}
+	if o.TopN <= 0 {
+		o.TopN = DefaultSlowTopN
+	}
+
+	return &InstrumentedQuerier{
+		Querier:       q,
+		name:          o.Name,
+		slowThreshold: o.SlowThreshold,
+		stats:         getOrAddQueryStats(o.Name, o.TopN),
+	}
+}
+
+// Stats return the stats of q
+func (q *InstrumentedQuerier) Stats() *QueryStats {
+	return q.stats
+}
+
+func (q *InstrumentedQuerier) unwrap() Querier {
+	return q.Querier
+}
+
+func (q *InstrumentedQuerier) rewrap(inner Querier) Querier {
+	w := *q
+	w.Querier = inner
+	return &w
+}
+
+func (q *InstrumentedQuerier) observe(ctx context.Context, query string, args []interface{}) func(err error) {
+	clock := qclock.From(ctx)
+	start := clock.Now()
+
+	return func(err error) {
+		d := clock.Since(start)
+		stmt := normalizeQuery(query)
+		q.stats.add(stmt, d, err)
+
+		if q.slowThreshold > 0 && d >= q.slowThreshold {
+			entry := log.WithFields(logrus.Fields{"db": q.name, "duration": d.String(), "args": redactArgs(args)})
+			if err != nil {
+				entry = entry.WithError(err)
+			}
+			entry.Warnf("slow query: %s", stmt)
+		}
+	}
+}
+
+// Exec implements Querier
+func (q *InstrumentedQuerier) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return q.ExecContext(context.Background(), query, args...)
+}
+
+// Query implements Querier
+func (q *InstrumentedQuerier) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return q.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow implements Querier
+func (q *InstrumentedQuerier) QueryRow(query string, args ...interface{}) *sql.Row {
+	return q.QueryRowContext(context.Background(), query, args...)
+}
+
+// ExecContext implements Querier
+func (q *InstrumentedQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	done := q.observe(ctx, query, args)
+	result, err := q.Querier.ExecContext(ctx, query, args...)
+	done(err)
+	return result, err
+}
+
+// QueryContext implements Querier, the time is until the first result is ready
+func (q *InstrumentedQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	done := q.observe(ctx, query, args)
+	rows, err := q.Querier.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+// QueryRowContext implements Querier
+func (q *InstrumentedQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	done := q.observe(ctx, query, args)
+	row := q.Querier.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// normalizeQuery collapse spaces of query as the stats key
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// redactArgs show only the types of args, ex "[string(5) int64 <nil>]"
+func redactArgs(args []interface{}) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			parts[i] = "<nil>"
+		case string:
+			parts[i] = fmt.Sprintf("string(%d)", len(v))
+		case []byte:
+			parts[i] = fmt.Sprintf("[]byte(%d)", len(v))
+		default:
+			parts[i] = fmt.Sprintf("%T", v)
+		}
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
@@ -0,0 +1,102 @@
+package qdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kkkbird/qapp/qclock"
+)
+
+func TestInstrument(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	clock := qclock.NewFake(time.Now())
+	ctx := qclock.WithClock(context.Background(), clock)
+	errExec := errors.New("exec failed")
+
+	latency := map[string]time.Duration{
+		"SELECT 1":      2 * time.Millisecond,
+		"UPDATE t":      300 * time.Millisecond,
+		"DELETE FROM t": 20 * time.Millisecond,
+	}
+	f.onExec = func(query string, args []driver.NamedValue) (driver.Result, error) {
+		clock.Advance(latency[query])
+		if query == "DELETE FROM t" {
+			return nil, errExec
+		}
+		return driver.RowsAffected(1), nil
+	}
+	f.onQuery = func(query string, args []driver.NamedValue) (driver.Rows, error) {
+		clock.Advance(latency[query])
+		return newFakeRows("n", []driver.Value{int64(1)}), nil
+	}
+
+	q := Instrument(db, &InstrumentOptions{Name: "test"})
+	if Instrument(db, &InstrumentOptions{Name: "test"}).Stats() != q.Stats() || GetQueryStats("test") != q.Stats() {
+		t.Fatalf("Queriers with the same name should share stats")
+	}
+
+	var n int
+	for i := 0; i < 3; i++ {
+		q.QueryRowContext(ctx, "SELECT  1").Scan(&n)
+	}
+	q.ExecContext(ctx, "UPDATE t", "secret", 1)
+	if _, err := q.ExecContext(ctx, "DELETE FROM t"); !errors.Is(err, errExec) {
+		t.Errorf("Err mismatch: %v", err)
+	}
+
+	// queries in transaction are instrumented too
+	err := WithTx(ctx, q, nil, func(tx Querier) error {
+		return WithTx(ctx, tx, nil, func(tx Querier) error {
+			_, err := tx.ExecContext(ctx, "UPDATE t")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTx error: %v", err)
+	}
+
+	list := q.Stats().List()
+	counts := make(map[string]int64)
+	for _, st := range list {
+		counts[st.Query] = st.Count
+	}
+	expectedCounts := map[string]int64{"SELECT 1": 3, "UPDATE t": 2, "DELETE FROM t": 1}
+	if !reflect.DeepEqual(counts, expectedCounts) {
+		t.Errorf("Counts mismatch: got %v, want %v", counts, expectedCounts)
+	}
+
+	slowest := q.Stats().Slowest(2)
+	if len(slowest) != 2 || slowest[0].Query != "UPDATE t" || slowest[1].Query != "DELETE FROM t" {
+		t.Fatalf("Slowest mismatch: %+v", slowest)
+	}
+	if slowest[0].Max != 300*time.Millisecond || slowest[0].Avg() != 300*time.Millisecond {
+		t.Errorf("Latency mismatch: max %v avg %v", slowest[0].Max, slowest[0].Avg())
+	}
+	if slowest[1].Errors != 1 {
+		t.Errorf("Errors mismatch: %d", slowest[1].Errors)
+	}
+	for _, b := range slowest[0].Histogram {
+		if expected := map[string]int64{"500ms": 2}[b.Le]; b.Count != expected {
+			t.Errorf("Histogram bucket %s mismatch: got %d, want %d", b.Le, b.Count, expected)
+		}
+	}
+
+	q.Stats().Reset()
+	if len(q.Stats().List()) != 0 {
+		t.Errorf("Stats should be reset")
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	got := redactArgs([]interface{}{"password", []byte("abc"), 42, nil, NewJSONB(1)})
+	expected := "[string(8) []byte(3) int <nil> qdb.JSONB[int]]"
+	if got != expected {
+		t.Errorf("redactArgs mismatch: got %s, want %s", got, expected)
+	}
+}
@@ -1,16 +1,22 @@
 package qdb
 
 import (
+	"bytes"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"io"
 	"reflect"
 )
 
 var (
-	ErrWrongJsonBType = errors.New("jsonb scan failed.")
+	ErrWrongJsonBType    = errors.New("jsonb scan failed.")
+	ErrJsonBTrailingData = errors.New("jsonb has data after the top-level value")
 )
 
+// JsonB is the wrapper returned by WrapJSONB
+//
+// Deprecated: use JSONB[T]
 type JsonB struct {
 	data interface{}
 }
@@ -45,14 +51,141 @@ func (jb *JsonB) Value() (driver.Value, error) {
 	return j, err
 }
 
-// JSONB wrapp func
+func (jb *JsonB) isTextValue() {}
+
+// WrapJSONB wrapp func, it was the func JSONB before the name is taken by JSONB[T]
 // usage example:
 // var data SampleData
-// db.QueryRow(sqlstr).Scan(qdb.JSONB(&data))
-func JSONB(d interface{}) *JsonB {
+// db.QueryRow(sqlstr).Scan(qdb.WrapJSONB(&data))
+//
+// Deprecated: use JSONB[T], which could be a struct field and reports NULL by Valid
+func WrapJSONB(d interface{}) *JsonB {
 	if reflect.TypeOf(d).Kind() != reflect.Ptr {
 		panic("error JSONB data, must use pointer")
 	}
 
 	return &JsonB{data: d}
 }
+
+// JSONB is a jsonb column decoded into V, NULL is scanned as Valid false, usage example:
+//
+//	type User struct {
+//		ID      int
+//		Profile qdb.JSONB[Profile]
+//	}
+//	db.QueryRow(sqlstr).Scan(&u.ID, &u.Profile)
+//
+// JSONB[json.RawMessage] keeps the raw bytes without re-encoding
+type JSONB[T any] struct {
+	V     T
+	Valid bool // Valid is true if the column is not NULL
+}
+
+// NewJSONB return a valid JSONB of v
+func NewJSONB[T any](v T) JSONB[T] {
+	return JSONB[T]{V: v, Valid: true}
+}
+
+// Scan implements the Scanner interface.
+func (j *JSONB[T]) Scan(src interface{}) error {
+	return j.scan(src, false)
+}
+
+func (j *JSONB[T]) scan(src interface{}, strict bool) error {
+	var data []byte
+
+	switch src := src.(type) {
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	case nil:
+		*j = JSONB[T]{}
+		return nil
+	default:
+		return ErrWrongJsonBType
+	}
+
+	var v T
+	if err := decodeJSON(data, &v, strict); err != nil {
+		return err
+	}
+
+	j.V, j.Valid = v, true
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (j JSONB[T]) Value() (driver.Value, error) {
+	if !j.Valid {
+		return nil, nil
+	}
+
+	if raw, ok := any(j.V).(json.RawMessage); ok {
+		if raw == nil {
+			return []byte("null"), nil
+		}
+		return []byte(raw), nil
+	}
+
+	return json.Marshal(j.V)
+}
+
+func (j JSONB[T]) isTextValue() {}
+
+// MarshalJSON implements json.Marshaler, invalid JSONB is null
+func (j JSONB[T]) MarshalJSON() ([]byte, error) {
+	if !j.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(j.V)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, null is invalid JSONB
+func (j *JSONB[T]) UnmarshalJSON(data []byte) error {
+	return j.unmarshalJSON(data, false)
+}
+
+func (j *JSONB[T]) unmarshalJSON(data []byte, strict bool) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*j = JSONB[T]{}
+		return nil
+	}
+	return j.scan(data, strict)
+}
+
+// StrictJSONB is JSONB which rejects unknown fields and trailing data when decoding
+type StrictJSONB[T any] struct {
+	JSONB[T]
+}
+
+// NewStrictJSONB return a valid StrictJSONB of v
+func NewStrictJSONB[T any](v T) StrictJSONB[T] {
+	return StrictJSONB[T]{NewJSONB(v)}
+}
+
+// Scan implements the Scanner interface.
+func (j *StrictJSONB[T]) Scan(src interface{}) error {
+	return j.scan(src, true)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, null is invalid JSONB
+func (j *StrictJSONB[T]) UnmarshalJSON(data []byte) error {
+	return j.unmarshalJSON(data, true)
+}
+
+func decodeJSON(data []byte, v interface{}, strict bool) error {
+	if !strict {
+		return json.Unmarshal(data, v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return ErrJsonBTrailingData
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package qdb
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type jsonbSample struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestJSONBScanValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       interface{}
+		expected  JSONB[jsonbSample]
+		wantValue interface{}
+	}{
+		{"Bytes", []byte(`{"name":"a","age":1}`), NewJSONB(jsonbSample{"a", 1}), `{"name":"a","age":1}`},
+		{"String", `{"name":"b"}`, NewJSONB(jsonbSample{Name: "b"}), `{"name":"b","age":0}`},
+		{"Unknown field", `{"name":"c","extra":true}`, NewJSONB(jsonbSample{Name: "c"}), `{"name":"c","age":0}`},
+		{"NULL", nil, JSONB[jsonbSample]{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := NewJSONB(jsonbSample{"old", 99}) // scan overwrites previous value
+
+			if err := j.Scan(tt.src); err != nil {
+				t.Fatalf("Scan error: %v", err)
+			}
+			if j != tt.expected {
+				t.Errorf("Scan mismatch: got %+v, want %+v", j, tt.expected)
+			}
+
+			v, err := j.Value()
+			if err != nil {
+				t.Fatalf("Value error: %v", err)
+			}
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			if v != tt.wantValue {
+				t.Errorf("Value mismatch: got %v, want %v", v, tt.wantValue)
+			}
+		})
+	}
+
+	var j JSONB[jsonbSample]
+	if err := j.Scan(1); !errors.Is(err, ErrWrongJsonBType) {
+		t.Errorf("Scan of int: got %v, want %v", err, ErrWrongJsonBType)
+	}
+}
+
+func TestJSONBRawMessage(t *testing.T) {
+	const raw = `{"b": 1,  "a": [1, 2]}`
+
+	var j JSONB[json.RawMessage]
+	if err := j.Scan([]byte(raw)); err != nil {
+		t.Fatalf("Scan error: %v", err)
+	}
+
+	v, err := j.Value()
+	if err != nil {
+		t.Fatalf("Value error: %v", err)
+	}
+	if string(v.([]byte)) != raw {
+		t.Errorf("RawMessage should pass through: got %s", v)
+	}
+}
+
+func TestStrictJSONB(t *testing.T) {
+	var j StrictJSONB[jsonbSample]
+
+	if err := j.Scan(`{"name":"a","extra":1}`); err == nil {
+		t.Errorf("Unknown field should be rejected")
+	}
+	if err := j.Scan(`{"name":"a"} {}`); !errors.Is(err, ErrJsonBTrailingData) {
+		t.Errorf("Trailing data: got %v, want %v", err, ErrJsonBTrailingData)
+	}
+	if err := j.Scan(`{"name":"a","age":2}`); err != nil || j.V != (jsonbSample{"a", 2}) || !j.Valid {
+		t.Errorf("Scan mismatch: %+v, %v", j, err)
+	}
+
+	var s struct {
+		Data StrictJSONB[jsonbSample] `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(`{"data":{"nick":"x"}}`), &s); err == nil {
+		t.Errorf("Unknown field should be rejected by UnmarshalJSON")
+	}
+}
+
+func TestJSONBMarshalJSON(t *testing.T) {
+	type wrapper struct {
+		A JSONB[jsonbSample] `json:"a"`
+		B JSONB[[]int]       `json:"b"`
+	}
+
+	in := wrapper{A: NewJSONB(jsonbSample{"a", 1})}
+
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	if string(b) != `{"a":{"name":"a","age":1},"b":null}` {
+		t.Errorf("Marshal mismatch: %s", b)
+	}
+
+	out := wrapper{B: NewJSONB([]int{1})}
+	if err = json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if out.A != in.A || out.B.Valid || out.B.V != nil {
+		t.Errorf("Unmarshal mismatch: %+v", out)
+	}
+}
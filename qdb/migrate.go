@@ -0,0 +1,465 @@
+package qdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kkkbird/qapp"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// DefaultMigrationTable is the default table of applied migrations
+const DefaultMigrationTable = "schema_migrations"
+
+// config key under "db.<name>." of the migration action
+const ConfigMigrate = "migrate"
+
+// migration actions, "auto" migrates to the latest version and continues, others exit the app after done
+const (
+	MigrateAuto   = "auto"
+	MigrateUp     = "up"
+	MigrateDown   = "down"
+	MigrateTo     = "to:" // ex "to:20240101"
+	MigrateStatus = "status"
+)
+
+// migration states of MigrationStatus
+const (
+	MigrationPending = "pending"
+	MigrationApplied = "applied"
+	MigrationDrift   = "drift"   // applied but the up sql is changed
+	MigrationMissing = "missing" // applied but the file is removed
+)
+
+var (
+	ErrMigrationChecksum = errors.New("migration checksum mismatch")
+	ErrMigrationNoDown   = errors.New("migration has no down sql")
+	ErrMigrationVersion  = errors.New("unknown migration version")
+)
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change of files "<version>_<name>.up.sql" and "<version>_<name>.down.sql"
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of Up
+}
+
+// MigrationStatus is the state of a migration version
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	State     string
+	AppliedAt time.Time
+}
+
+// Migrator apply migrations to PostgreSQL, an advisory lock is held while migrating so only one replica migrates
+type Migrator struct {
+	Table  string // table of applied migrations, default DefaultMigrationTable
+	LockID int64  // advisory lock key, default hash of Table
+
+	migrations []*Migration
+}
+
+// NewMigrator read migrations from the root of fsys, use fs.Sub for a sub dir, usage example:
+//
+//	//go:embed migrations/*.sql
+//	var migrationFS embed.FS
+//
+//	sub, _ := fs.Sub(migrationFS, "migrations")
+//	m, err := qdb.NewMigrator(sub)
+func NewMigrator(fsys fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		match := migrationFileRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mg
+		} else if mg.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d has different names %s and %s", version, mg.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			mg.Up = string(data)
+			sum := sha256.Sum256(data)
+			mg.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mg.Down = string(data)
+		}
+	}
+
+	m := &Migrator{Table: DefaultMigrationTable}
+	for _, mg := range byVersion {
+		if mg.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up sql", mg.Version, mg.Name)
+		}
+		m.migrations = append(m.migrations, mg)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+
+	return m, nil
+}
+
+// Migrations return all migrations ordered by version
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+func (m *Migrator) table() string {
+	if m.Table == "" {
+		return DefaultMigrationTable
+	}
+	return m.Table
+}
+
+func (m *Migrator) lockID() int64 {
+	if m.LockID != 0 {
+		return m.LockID
+	}
+	h := fnv.New64a()
+	h.Write([]byte("qdb.migrate." + m.table()))
+	return int64(h.Sum64())
+}
+
+// Latest return the latest version, 0 if no migration
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// applied return applied migrations, empty if the table does not exist
+func (m *Migrator) applied(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}) (map[int64]appliedMigration, error) {
+	var exists sql.NullString
+	if err := q.QueryRowContext(ctx, "SELECT to_regclass($1)::text", m.table()).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]appliedMigration)
+	if !exists.Valid {
+		return applied, nil
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM "+m.table())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			version int64
+			a       appliedMigration
+		)
+		if err = rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// Status return the state of all migrations and applied versions without files
+func (m *Migrator) Status(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		s := MigrationStatus{Version: mg.Version, Name: mg.Name, State: MigrationPending}
+		if a, ok := applied[mg.Version]; ok {
+			s.State, s.AppliedAt = MigrationApplied, a.appliedAt
+			if a.checksum != mg.Checksum {
+				s.State = MigrationDrift
+			}
+			delete(applied, mg.Version)
+		}
+		status = append(status, s)
+	}
+
+	for version, a := range applied {
+		status = append(status, MigrationStatus{Version: version, Name: a.name, State: MigrationMissing, AppliedAt: a.appliedAt})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+
+	return status, nil
+}
+
+// Up apply all pending migrations, it never reverts, ex applied versions without files after a rollback deploy are kept
+func (m *Migrator) Up(ctx context.Context, db *sql.DB) error {
+	return m.withLock(ctx, db, func(conn *sql.Conn, applied map[int64]appliedMigration) error {
+		return m.apply(ctx, conn, applied, math.MaxInt64)
+	})
+}
+
+// Down revert the latest applied migration, it never applies
+func (m *Migrator) Down(ctx context.Context, db *sql.DB) error {
+	return m.withLock(ctx, db, func(conn *sql.Conn, applied map[int64]appliedMigration) error {
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		if len(versions) == 0 {
+			log.Info("no migration to revert")
+			return nil
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		// revert down to the next lower applied version, pending versions below are not applied
+		target := int64(0)
+		if len(versions) > 1 {
+			target = versions[1]
+		}
+		return m.revert(ctx, conn, applied, target)
+	})
+}
+
+// To migrate up or down to version, migrations after version are reverted and the others are applied
+func (m *Migrator) To(ctx context.Context, db *sql.DB, version int64) error {
+	return m.withLock(ctx, db, func(conn *sql.Conn, applied map[int64]appliedMigration) error {
+		return m.migrateTo(ctx, conn, applied, version)
+	})
+}
+
+// withLock run fn on a connection holding the advisory lock, after checking checksum drift
+func (m *Migrator) withLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn, applied map[int64]appliedMigration) error) (err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", m.lockID()); err != nil {
+		return fmt.Errorf("lock migration: %w", err)
+	}
+	defer func() {
+		// unlock even if ctx is done, the lock is released with the session anyway
+		if _, unlockErr := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", m.lockID()); unlockErr != nil {
+			log.WithError(unlockErr).Warn("unlock migration failed")
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+m.table()+` (
+	version BIGINT PRIMARY KEY,
+	name TEXT NOT NULL,
+	checksum TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return err
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, mg := range m.migrations {
+		if a, ok := applied[mg.Version]; ok && a.checksum != mg.Checksum {
+			return fmt.Errorf("%w: %d_%s is changed after applied", ErrMigrationChecksum, mg.Version, mg.Name)
+		}
+	}
+
+	return fn(conn, applied)
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return mg
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) migrateTo(ctx context.Context, conn *sql.Conn, applied map[int64]appliedMigration, target int64) error {
+	if target != 0 && m.find(target) == nil {
+		if _, ok := applied[target]; !ok {
+			return fmt.Errorf("%w: %d", ErrMigrationVersion, target)
+		}
+	}
+
+	if err := m.revert(ctx, conn, applied, target); err != nil {
+		return err
+	}
+	return m.apply(ctx, conn, applied, target)
+}
+
+// revert applied versions after target, latest first
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, applied map[int64]appliedMigration, target int64) error {
+	var reverts []int64
+	for v := range applied {
+		if v > target {
+			reverts = append(reverts, v)
+		}
+	}
+	sort.Slice(reverts, func(i, j int) bool { return reverts[i] > reverts[j] })
+
+	for _, v := range reverts {
+		mg := m.find(v)
+		if mg == nil || strings.TrimSpace(mg.Down) == "" {
+			return fmt.Errorf("%w: %d", ErrMigrationNoDown, v)
+		}
+
+		log.Infof("revert migration %d_%s", mg.Version, mg.Name)
+		err := runTx(ctx, conn, nil, func(q Querier) error {
+			if _, err := q.ExecContext(ctx, mg.Down); err != nil {
+				return err
+			}
+			_, err := q.ExecContext(ctx, "DELETE FROM "+m.table()+" WHERE version = $1", mg.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("revert migration %d_%s: %w", mg.Version, mg.Name, err)
+		}
+	}
+	return nil
+}
+
+// apply pending migrations up to target
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, applied map[int64]appliedMigration, target int64) error {
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; ok || mg.Version > target {
+			continue
+		}
+
+		log.Infof("apply migration %d_%s", mg.Version, mg.Name)
+		err := runTx(ctx, conn, nil, func(q Querier) error {
+			if _, err := q.ExecContext(ctx, mg.Up); err != nil {
+				return err
+			}
+			_, err := q.ExecContext(ctx, "INSERT INTO "+m.table()+" (version, name, checksum) VALUES ($1, $2, $3)", mg.Version, mg.Name, mg.Checksum)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("apply migration %d_%s: %w", mg.Version, mg.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// printMigrationStatus write status as a table
+func printMigrationStatus(w io.Writer, status []MigrationStatus) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range status {
+		appliedAt := ""
+		if !s.AppliedAt.IsZero() {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, appliedAt)
+	}
+	tw.Flush()
+}
+
+// MigrateInitFunc return an init func running the migration action of flag/config "db.<name>.migrate" on DB(name),
+// it should be added in a stage after InitFunc(name). actions are
+//
+//	auto        apply pending migrations and continue
+//	up          apply pending migrations and exit
+//	down        revert the latest migration and exit
+//	to:VERSION  migrate up or down to VERSION and exit
+//	status      print migration status and exit
+//
+// no action means do nothing, usage example:
+//
+//	app.AddInitStage("db", qdb.InitFunc("main")).
+//		AddInitStage("migrate", qdb.MigrateInitFunc("main", m))
+func MigrateInitFunc(name string, m *Migrator) qapp.InitFunc {
+	key := dbConfigKey(name, ConfigMigrate)
+	if pflag.Lookup(key) == nil {
+		pflag.String(key, "", "migration action of db "+name+": auto, up, down, to:VERSION or status")
+	}
+
+	return func(ctx context.Context) (qapp.CleanFunc, error) {
+		action := viper.GetString(key)
+		if action == "" {
+			return nil, nil
+		}
+
+		db := DB(name)
+		if db == nil {
+			return nil, fmt.Errorf("db %s is not opened, add qdb.InitFunc(%q) in a previous stage", name, name)
+		}
+
+		var err error
+		switch {
+		case action == MigrateAuto:
+			return nil, m.Up(ctx, db)
+		case action == MigrateUp:
+			err = m.Up(ctx, db)
+		case action == MigrateDown:
+			err = m.Down(ctx, db)
+		case strings.HasPrefix(action, MigrateTo):
+			var version int64
+			if version, err = strconv.ParseInt(strings.TrimPrefix(action, MigrateTo), 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", key, action, err)
+			}
+			err = m.To(ctx, db, version)
+		case action == MigrateStatus:
+			var status []MigrationStatus
+			if status, err = m.Status(ctx, db); err == nil {
+				printMigrationStatus(os.Stdout, status)
+			}
+		default:
+			return nil, fmt.Errorf("unknown %s %q", key, action)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		return nil, qapp.ErrExit
+	}
+}
@@ -0,0 +1,266 @@
+package qdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakeMigrationDB keep the migration table in memory
+func fakeMigrationDB(f *fakeDB) map[int64][]driver.Value {
+	table := make(map[int64][]driver.Value)
+	created := false
+
+	f.onExec = func(query string, args []driver.NamedValue) (driver.Result, error) {
+		switch {
+		case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+			created = true
+		case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+			v := args[0].Value.(int64)
+			table[v] = []driver.Value{v, args[1].Value, args[2].Value, time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)}
+		case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+			delete(table, args[0].Value.(int64))
+		}
+		return driver.RowsAffected(1), nil
+	}
+	f.onQuery = func(query string, args []driver.NamedValue) (driver.Rows, error) {
+		switch {
+		case strings.HasPrefix(query, "SELECT to_regclass"):
+			if created {
+				return newFakeRows("to_regclass", []driver.Value{"schema_migrations"}), nil
+			}
+			return newFakeRows("to_regclass", []driver.Value{nil}), nil
+		case strings.HasPrefix(query, "SELECT version"):
+			rows := newFakeRows("version,name,checksum,applied_at")
+			for _, row := range table {
+				rows.values = append(rows.values, row)
+			}
+			return rows, nil
+		}
+		return nil, errors.New("unexpected query " + query)
+	}
+
+	return table
+}
+
+func TestMigrator(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_users.up.sql":     {Data: []byte("CREATE TABLE users")},
+		"1_users.down.sql":   {Data: []byte("DROP TABLE users")},
+		"2_orders.up.sql":    {Data: []byte("CREATE TABLE orders")},
+		"2_orders.down.sql":  {Data: []byte("DROP TABLE orders")},
+		"10_index.up.sql":    {Data: []byte("CREATE INDEX i")},
+		"readme.md":          {Data: []byte("not a migration")},
+		"10_index.down.sql":  {Data: []byte("DROP INDEX i")},
+		"sub/3_skip.up.sql":  {Data: []byte("SKIPPED")},
+		"3_nodown.up.sql.bk": {Data: []byte("SKIPPED")},
+	}
+
+	m, err := NewMigrator(fsys)
+	if err != nil {
+		t.Fatalf("NewMigrator error: %v", err)
+	}
+	if len(m.Migrations()) != 3 || m.Latest() != 10 {
+		t.Fatalf("Migrations mismatch: %d, latest %d", len(m.Migrations()), m.Latest())
+	}
+
+	f, db := newFakeDB()
+	defer db.Close()
+	table := fakeMigrationDB(f)
+	ctx := context.Background()
+
+	status, err := m.Status(ctx, db)
+	if err != nil || len(status) != 3 || status[0].State != MigrationPending {
+		t.Fatalf("Status mismatch: %+v, %v", status, err)
+	}
+	f.statements()
+
+	// up
+	if err = m.Up(ctx, db); err != nil {
+		t.Fatalf("Up error: %v", err)
+	}
+	stmts := f.statements()
+	expected := []string{
+		"SELECT pg_advisory_lock($1)",
+		"CREATE TABLE", "SELECT to_regclass($1)::text", "SELECT version, name, checksum, applied_at FROM schema_migrations",
+		"BEGIN", "CREATE TABLE users", "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)", "COMMIT",
+		"BEGIN", "CREATE TABLE orders", "INSERT", "COMMIT",
+		"BEGIN", "CREATE INDEX i", "INSERT", "COMMIT",
+		"SELECT pg_advisory_unlock($1)",
+	}
+	if len(stmts) != len(expected) {
+		t.Fatalf("Statements mismatch:\n got %q\nwant %q", stmts, expected)
+	}
+	for i := range stmts {
+		if !strings.HasPrefix(stmts[i], expected[i]) {
+			t.Errorf("Statement %d mismatch: got %q, want %q", i, stmts[i], expected[i])
+		}
+	}
+	if len(table) != 3 {
+		t.Fatalf("Applied mismatch: %v", table)
+	}
+
+	// up again does nothing
+	m.Up(ctx, db)
+	for _, stmt := range f.statements() {
+		if stmt == "BEGIN" {
+			t.Errorf("Nothing should be applied")
+		}
+	}
+
+	// down revert the latest
+	if err = m.Down(ctx, db); err != nil {
+		t.Fatalf("Down error: %v", err)
+	}
+	if stmts := f.statements(); !contains(stmts, "DROP INDEX i") || contains(stmts, "DROP TABLE orders") {
+		t.Errorf("Down statements mismatch: %q", stmts)
+	}
+
+	// to version 1, then back to 10
+	if err = m.To(ctx, db, 1); err != nil || len(table) != 1 {
+		t.Fatalf("To 1 mismatch: %v, %v", table, err)
+	}
+	if err = m.To(ctx, db, 10); err != nil || len(table) != 3 {
+		t.Fatalf("To 10 mismatch: %v, %v", table, err)
+	}
+	if err = m.To(ctx, db, 5); !errors.Is(err, ErrMigrationVersion) {
+		t.Errorf("To unknown version: got %v, want %v", err, ErrMigrationVersion)
+	}
+
+	// drift of applied migration
+	fsys["2_orders.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE orders2")}
+	delete(fsys, "10_index.up.sql")
+	delete(fsys, "10_index.down.sql")
+	m2, _ := NewMigrator(fsys)
+	if err = m2.Up(ctx, db); !errors.Is(err, ErrMigrationChecksum) {
+		t.Errorf("Drift: got %v, want %v", err, ErrMigrationChecksum)
+	}
+	if stmts := f.statements(); stmts[len(stmts)-1] != "SELECT pg_advisory_unlock($1)" {
+		t.Errorf("Lock should be released on error: %q", stmts)
+	}
+
+	status, _ = m2.Status(ctx, db)
+	var states []string
+	for _, s := range status {
+		states = append(states, s.State)
+	}
+	if !reflect.DeepEqual(states, []string{MigrationApplied, MigrationDrift, MigrationMissing}) {
+		t.Errorf("States mismatch: %v", states)
+	}
+
+	buf := new(bytes.Buffer)
+	printMigrationStatus(buf, status)
+	if !strings.Contains(buf.String(), "10       index   missing  2024-06-1") {
+		t.Errorf("Status output mismatch:\n%s", buf)
+	}
+}
+
+func TestMigratorUpNeverReverts(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	table := fakeMigrationDB(f)
+	ctx := context.Background()
+
+	full, _ := NewMigrator(fstest.MapFS{
+		"1_users.up.sql":    {Data: []byte("CREATE TABLE users")},
+		"2_orders.up.sql":   {Data: []byte("CREATE TABLE orders")},
+		"2_orders.down.sql": {Data: []byte("DROP TABLE orders")},
+	})
+	if err := full.Up(ctx, db); err != nil || len(table) != 2 {
+		t.Fatalf("Up mismatch: %v, %v", table, err)
+	}
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "DB ahead of files",
+			fsys: fstest.MapFS{"1_users.up.sql": {Data: []byte("CREATE TABLE users")}},
+		},
+		{
+			name: "Empty FS",
+			fsys: fstest.MapFS{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMigrator(tt.fsys)
+			if err != nil {
+				t.Fatalf("NewMigrator error: %v", err)
+			}
+			f.statements()
+
+			if err = m.Up(ctx, db); err != nil {
+				t.Fatalf("Up error: %v", err)
+			}
+			if stmts := f.statements(); contains(stmts, "BEGIN") {
+				t.Errorf("Nothing should be applied or reverted: %q", stmts)
+			}
+			if len(table) != 2 {
+				t.Errorf("Applied versions should be kept: %v", table)
+			}
+		})
+	}
+}
+
+func TestMigratorDownNeverApplies(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	table := fakeMigrationDB(f)
+	ctx := context.Background()
+
+	files := fstest.MapFS{
+		"2_orders.up.sql":   {Data: []byte("CREATE TABLE orders")},
+		"2_orders.down.sql": {Data: []byte("DROP TABLE orders")},
+		"3_items.up.sql":    {Data: []byte("CREATE TABLE items")},
+		"3_items.down.sql":  {Data: []byte("DROP TABLE items")},
+	}
+	m, _ := NewMigrator(files)
+	if err := m.Up(ctx, db); err != nil || len(table) != 2 {
+		t.Fatalf("Up mismatch: %v, %v", table, err)
+	}
+
+	// version 1 is merged after 2 and 3 are applied
+	files["1_users.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE users")}
+	m, _ = NewMigrator(files)
+	f.statements()
+
+	if err := m.Down(ctx, db); err != nil {
+		t.Fatalf("Down error: %v", err)
+	}
+	if stmts := f.statements(); !contains(stmts, "DROP TABLE items") || contains(stmts, "CREATE TABLE users") {
+		t.Errorf("Down should only revert version 3: %q", stmts)
+	}
+	if _, ok := table[2]; !ok || len(table) != 1 {
+		t.Errorf("Down applied versions mismatch: %v", table)
+	}
+}
+
+func TestNewMigratorError(t *testing.T) {
+	_, err := NewMigrator(fstest.MapFS{"1_a.down.sql": {Data: []byte("x")}})
+	if err == nil {
+		t.Errorf("Migration without up sql should fail")
+	}
+
+	_, err = NewMigrator(fstest.MapFS{"1_a.up.sql": {Data: []byte("x")}, "1_b.up.sql": {Data: []byte("x")}})
+	if err == nil {
+		t.Errorf("Duplicated version should fail")
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package qdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNamedArg = errors.New("named arg must be a struct, pointer to struct or map[string]interface{}")
+)
+
+// compileNamed rewrite ":name" parameters of query to "$n", the same name is bound once.
+// "::" casts, array slices like "arr[1:n]", and parameters in quoted strings, dollar-quoted strings,
+// quoted identifiers and comments are kept as is
+func compileNamed(query string) (string, []string) {
+	var (
+		b     strings.Builder
+		names []string
+		seen  = make(map[string]int)
+	)
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '\'' || c == '"': // quoted string or identifier, '' and "" are escaped quotes
+			end := quoteEnd(query, i, isEscapeString(query, i))
+			b.WriteString(query[i:end])
+			i = end - 1
+		case c == '$' && (i == 0 || !isNameChar(query[i-1])) && dollarTag(query[i:]) != "": // dollar-quoted string, ex $$...$$ or $tag$...$tag$
+			tag := dollarTag(query[i:])
+			end := len(query)
+			if n := strings.Index(query[i+len(tag):], tag); n >= 0 {
+				end = i + len(tag) + n + len(tag)
+			}
+			b.WriteString(query[i:end])
+			i = end - 1
+		case c == '-' && i+1 < len(query) && query[i+1] == '-': // line comment
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '/' && i+1 < len(query) && query[i+1] == '*': // block comment
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 2
+			} else {
+				end += 2
+			}
+			b.WriteString(query[i : i+2+end])
+			i += 1 + end
+		case c == ':' && i+1 < len(query) && query[i+1] == ':': // cast
+			b.WriteString("::")
+			i++
+		case c == ':' && i+1 < len(query) && isNameChar(query[i+1]) && (i == 0 || (!isNameChar(query[i-1]) && query[i-1] != '[')):
+			end := i + 1
+			for end < len(query) && isNameChar(query[end]) {
+				end++
+			}
+			name := query[i+1 : end]
+
+			n, ok := seen[name]
+			if !ok {
+				names = append(names, name)
+				n = len(names)
+				seen[name] = n
+			}
+			b.WriteString("$" + strconv.Itoa(n))
+			i = end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), names
+}
+
+// isEscapeString check if the quote at i starts an escape string constant, ex E'it\'s'
+func isEscapeString(query string, i int) bool {
+	return query[i] == '\'' && i > 0 && (query[i-1] == 'E' || query[i-1] == 'e') && (i < 2 || !isNameChar(query[i-2]))
+}
+
+// quoteEnd return the index after the closing quote of the quoted string at start
+func quoteEnd(query string, start int, backslash bool) int {
+	q := query[start]
+	for end := start + 1; end < len(query); end++ {
+		switch {
+		case backslash && query[end] == '\\':
+			end++
+		case query[end] == q:
+			if end+1 < len(query) && query[end+1] == q {
+				end++
+				continue
+			}
+			return end + 1
+		}
+	}
+	return len(query)
+}
+
+// dollarTag return the dollar quote tag at the start of s, ex "$$" or "$fn$", "" if s is not a dollar quote like "$1"
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			return s[:i+1]
+		case c >= '0' && c <= '9':
+			if i == 1 {
+				return ""
+			}
+		case !isNameChar(c):
+			return ""
+		}
+	}
+	return ""
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// Named rewrite ":name" parameters of query to "$n" and bind args from arg, a struct by `db` tags
+// like Get, or a map[string]interface{}, usage example:
+//
+//	query, args, err := qdb.Named("UPDATE users SET name = :name WHERE id = :id", u)
+func Named(query string, arg interface{}) (string, []interface{}, error) {
+	compiled, names := compileNamed(query)
+
+	lookup, err := namedLookup(arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		v, ok := lookup(name)
+		if !ok {
+			return "", nil, fmt.Errorf("no value for named parameter :%s", name)
+		}
+		args[i] = v
+	}
+	return compiled, args, nil
+}
+
+func namedLookup(arg interface{}) (func(name string) (interface{}, bool), error) {
+	if m, ok := arg.(map[string]interface{}); ok {
+		return func(name string) (interface{}, bool) {
+			v, ok := m[name]
+			return v, ok
+		}, nil
+	}
+
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, ErrNamedArg
+	}
+
+	fields := getStructFields(v.Type())
+	return func(name string) (interface{}, bool) {
+		index, ok := fields[name]
+		if !ok {
+			return nil, false
+		}
+
+		fv, err := v.FieldByIndexErr(index)
+		if err != nil { // through a nil embedded pointer
+			return nil, true
+		}
+		return fv.Interface(), true
+	}, nil
+}
+
+// NamedExec exec query with ":name" parameters bound from arg, see Named
+func NamedExec(ctx context.Context, q Querier, query string, arg interface{}) (sql.Result, error) {
+	compiled, args, err := Named(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return q.ExecContext(ctx, compiled, args...)
+}
+
+// NamedSelect query rows with ":name" parameters bound from arg into dest, see Named and Select
+func NamedSelect(ctx context.Context, q Querier, dest interface{}, query string, arg interface{}) error {
+	compiled, args, err := Named(query, arg)
+	if err != nil {
+		return err
+	}
+	return Select(ctx, q, dest, compiled, args...)
+}
@@ -0,0 +1,129 @@
+package qdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	u := scanUser{scanBase: scanBase{ID: 7}, UserName: "tom"}
+
+	tests := []struct {
+		name     string
+		query    string
+		arg      interface{}
+		expected string
+		args     []interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "Struct",
+			query:    "UPDATE users SET name = :name WHERE id = :id OR parent = :id",
+			arg:      &u,
+			expected: "UPDATE users SET name = $1 WHERE id = $2 OR parent = $2",
+			args:     []interface{}{"tom", int64(7)},
+		},
+		{
+			name:     "Map with cast",
+			query:    "SELECT :v::int, ':no', \":no\" -- :no\n/* :no */",
+			arg:      map[string]interface{}{"v": 1},
+			expected: "SELECT $1::int, ':no', \":no\" -- :no\n/* :no */",
+			args:     []interface{}{1},
+		},
+		{
+			name:     "Escaped quote",
+			query:    "SELECT 'it''s :no', :v",
+			arg:      map[string]interface{}{"v": 1},
+			expected: "SELECT 'it''s :no', $1",
+			args:     []interface{}{1},
+		},
+		{
+			name:     "Dollar quoted",
+			query:    "SELECT $$ :no $$, $fn$ it's :no $fn$, :v",
+			arg:      map[string]interface{}{"v": 1},
+			expected: "SELECT $$ :no $$, $fn$ it's :no $fn$, $1",
+			args:     []interface{}{1},
+		},
+		{
+			name:     "Positional and identifier dollar",
+			query:    "SELECT a$b$, $1, :v",
+			arg:      map[string]interface{}{"v": 1},
+			expected: "SELECT a$b$, $1, $1",
+			args:     []interface{}{1},
+		},
+		{
+			name:     "Escape string",
+			query:    `SELECT E'it\'s :no', e'\\', :v`,
+			arg:      map[string]interface{}{"v": 1},
+			expected: `SELECT E'it\'s :no', e'\\', $1`,
+			args:     []interface{}{1},
+		},
+		{
+			name:     "Array slice",
+			query:    "SELECT arr[1:n], arr[:n], arr[:v + 1]",
+			arg:      map[string]interface{}{"v": 1},
+			expected: "SELECT arr[1:n], arr[:n], arr[:v + 1]",
+			args:     []interface{}{},
+		},
+		{
+			name:    "Missing",
+			query:   "SELECT :missing",
+			arg:     u,
+			wantErr: true,
+		},
+		{
+			name:    "Wrong arg",
+			query:   "SELECT :v",
+			arg:     1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := Named(tt.query, tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Named() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if query != tt.expected {
+				t.Errorf("Named() query = %q, expected %q", query, tt.expected)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("Named() args = %v, expected %v", args, tt.args)
+			}
+		})
+	}
+
+	if _, _, err := Named("SELECT :v", 1); !errors.Is(err, ErrNamedArg) {
+		t.Errorf("Named() error = %v, expected ErrNamedArg", err)
+	}
+}
+
+func TestNamedExec(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	var got []driver.NamedValue
+	f.onExec = func(query string, args []driver.NamedValue) (driver.Result, error) {
+		got = args
+		return driver.RowsAffected(1), nil
+	}
+
+	u := scanUser{UserName: "tom", Location: Point{1, 2}}
+	if _, err := NamedExec(context.Background(), db, "INSERT INTO users (name, loc) VALUES (:name, :loc)", u); err != nil {
+		t.Fatalf("NamedExec() error = %v", err)
+	}
+
+	if stmts := f.statements(); len(stmts) != 1 || stmts[0] != "INSERT INTO users (name, loc) VALUES ($1, $2)" {
+		t.Errorf("NamedExec() statements = %v", stmts)
+	}
+	if len(got) != 2 || got[0].Value != "tom" || got[1].Value != "(1,2)" {
+		t.Errorf("NamedExec() args = %v", got)
+	}
+}
@@ -0,0 +1,48 @@
+package qdb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// Null is a nullable T, T must be a value type implements driver.Valuer, and *T implements sql.Scanner.
+// unlike sql.Null, Value returns the driver value of T, ex
+//
+//	var c qdb.Null[qdb.Circle]
+//	db.QueryRow(sqlstr).Scan(&c)
+type Null[T driver.Valuer] struct {
+	V     T
+	Valid bool // Valid is true if V is not NULL
+}
+
+// NewNull return a valid Null of v
+func NewNull[T driver.Valuer](v T) Null[T] {
+	return Null[T]{V: v, Valid: true}
+}
+
+// Scan implements the Scanner interface.
+func (n *Null[T]) Scan(src interface{}) error {
+	if src == nil {
+		*n = Null[T]{}
+		return nil
+	}
+
+	scanner, ok := any(&n.V).(sql.Scanner)
+	if !ok {
+		return fmt.Errorf("*%T does not implement sql.Scanner", n.V)
+	}
+	if err := scanner.Scan(src); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (n Null[T]) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.V.Value()
+}
@@ -1,14 +1,11 @@
 package qdb
 
 import (
-	"bytes"
 	"database/sql/driver"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
-
-	"github.com/lib/pq"
 )
 
 var (
@@ -20,7 +17,8 @@ type Point struct {
 	Y float64
 }
 
-// Scan implements the Scanner interface.
+// Scan implements the Scanner interface. NULL fails with ErrNullGeometry, use NullPoint for a nullable column,
+// it was scanned as a no-op before
 func (pt *Point) Scan(src interface{}) error {
 	switch src := src.(type) {
 	case []byte:
@@ -28,8 +26,7 @@ func (pt *Point) Scan(src interface{}) error {
 	case string:
 		return pt.scanBytes([]byte(src))
 	case nil:
-		pt = nil
-		return nil
+		return fmt.Errorf("%w: point", ErrNullGeometry)
 	}
 	return ErrWrongPointType
 
@@ -58,57 +55,13 @@ func (pt *Point) scanBytes(src []byte) error {
 }
 
 // Value implements the driver Valuer interface.
-func (pt *Point) Value() (driver.Value, error) {
-	buf := new(bytes.Buffer)
-	fmt.Fprintf(buf, "(%f, %f)", pt.X, pt.Y)
-	return buf.Bytes(), nil
+func (pt Point) Value() (driver.Value, error) {
+	return pt.String(), nil
 }
 
-type PointArray []Point
-
-// Scan implements the Scanner interface.
-func (a *PointArray) Scan(src interface{}) error {
-	var err error
-
-	var elems pq.StringArray
-
-	if err = elems.Scan(src); err != nil {
-		return err
-	}
-
-	if *a != nil && len(elems) == 0 {
-		*a = (*a)[:0]
-	} else {
-		b := make(PointArray, len(elems))
-		for i, v := range elems {
-			if err = b[i].Scan(v); err != nil {
-				return err
-			}
-		}
-		*a = b
-	}
-
-	return nil
+// String return the text format of point, ex "(1.5,2)"
+func (pt Point) String() string {
+	return "(" + formatFloat(pt.X) + "," + formatFloat(pt.Y) + ")"
 }
 
-// Value implements the driver Valuer interface.
-func (a PointArray) Value() (driver.Value, error) {
-	if a == nil {
-		return nil, nil
-	}
-
-	if n := len(a); n > 0 {
-		buf := new(bytes.Buffer)
-		buf.WriteString("{")
-		fmt.Fprintf(buf, "\"(%f,%f)\"", a[0].X, a[0].Y)
-		for i := 1; i < n; i++ {
-			buf.WriteByte(',')
-			fmt.Fprintf(buf, "\"(%f,%f)\"", a[i].X, a[i].Y)
-		}
-
-		buf.WriteString("}")
-
-		return buf.Bytes(), nil
-	}
-	return "{}", nil
-}
+type PointArray = Array[Point]
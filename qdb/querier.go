@@ -4,6 +4,12 @@ package qdb
 import (
 	"context"
 	"database/sql"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	log = logrus.WithField("pkg", "qdb")
 )
 
 // Querier is the common interface to execute queries on a DB, Tx, or Conn.
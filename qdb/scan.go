@@ -0,0 +1,228 @@
+package qdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrScanDest = errors.New("scan dest must be a pointer to struct or slice of struct")
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// structFields map column names to field index paths, embedded structs are flattened
+type structFields map[string][]int
+
+var structFieldsCache sync.Map // reflect.Type -> structFields
+
+// getStructFields return the column map of struct type t, column names are from `db` tag or snake case field names,
+// `db:"-"` fields are skipped
+func getStructFields(t reflect.Type) structFields {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.(structFields)
+	}
+
+	fields := make(structFields)
+	collectStructFields(t, nil, fields)
+
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+func collectStructFields(t reflect.Type, index []int, fields structFields) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("db")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		path := append(append([]int{}, index...), i)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+			if !f.IsExported() { // nil pointer of unexported type can't be allocated, like encoding/json
+				continue
+			}
+		}
+
+		// flatten embedded structs which are not a column type
+		if f.Anonymous && tag == "" && ft.Kind() == reflect.Struct && !isColumnType(ft) {
+			collectStructFields(ft, path, fields)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = snakeCase(f.Name)
+		}
+		if _, ok := fields[name]; !ok || len(path) < len(fields[name]) { // shallower field wins like Go
+			fields[name] = path
+		}
+	}
+}
+
+// isColumnType check if struct type t is scanned as a whole column, ex Point or time.Time
+func isColumnType(t reflect.Type) bool {
+	return t == timeType || reflect.PointerTo(t).Implements(scannerType)
+}
+
+// snakeCase convert field name to column name, ex "UserID" to "user_id"
+func snakeCase(name string) string {
+	runes := []rune(name)
+	b := new(strings.Builder)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// word boundary before an upper letter after lower, or before the last upper of an acronym
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex which allocates nil embedded pointers
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// ScanStruct scan the current row of rows into struct pointer dest, every column must have a field
+func ScanStruct(rows *sql.Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrScanDest
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	targets, err := scanTargets(v.Elem(), columns)
+	if err != nil {
+		return err
+	}
+	return rows.Scan(targets...)
+}
+
+func scanTargets(v reflect.Value, columns []string) ([]interface{}, error) {
+	fields := getStructFields(v.Type())
+
+	targets := make([]interface{}, len(columns))
+	for i, col := range columns {
+		index, ok := fields[col]
+		if !ok {
+			return nil, fmt.Errorf("no field of %s for column %q", v.Type(), col)
+		}
+		targets[i] = fieldByIndex(v, index).Addr().Interface()
+	}
+	return targets, nil
+}
+
+// Get query a row into struct pointer dest, sql.ErrNoRows is returned if no row, usage example:
+//
+//	var u User
+//	err := qdb.Get(ctx, db, &u, "SELECT id, name FROM users WHERE id = $1", id)
+func Get(ctx context.Context, q Querier, dest interface{}, query string, args ...interface{}) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+
+	if err = ScanStruct(rows, dest); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Select query rows into dest, dest is a pointer to slice of struct or struct pointer, usage example:
+//
+//	var users []User
+//	err := qdb.Select(ctx, db, &users, "SELECT * FROM users")
+func Select(ctx context.Context, q Querier, dest interface{}, query string, args ...interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return ErrScanDest
+	}
+
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return ErrScanDest
+	}
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, 0)
+	for rows.Next() {
+		elem := reflect.New(elemType)
+
+		targets, err := scanTargets(elem.Elem(), columns)
+		if err != nil {
+			return err
+		}
+		if err = rows.Scan(targets...); err != nil {
+			return err
+		}
+
+		if isPtr {
+			result = reflect.Append(result, elem)
+		} else {
+			result = reflect.Append(result, elem.Elem())
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	slice.Set(result)
+	return nil
+}
@@ -0,0 +1,137 @@
+package qdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type scanBase struct {
+	ID        int64
+	CreatedAt time.Time
+}
+
+// ScanProfile is exported to be allocated as embedded pointer
+type ScanProfile struct {
+	Bio string `db:"bio"`
+}
+
+type scanUser struct {
+	scanBase
+	*ScanProfile
+	UserName string             `db:"name"`
+	Location Point              `db:"loc"`
+	Extra    JSONB[jsonbSample] `db:"extra"`
+	Note     string             `db:"-"`
+	ignored  int
+}
+
+func TestGetStructFields(t *testing.T) {
+	fields := getStructFields(reflect.TypeOf(scanUser{}))
+
+	expected := structFields{
+		"id":         {0, 0},
+		"created_at": {0, 1},
+		"bio":        {1, 0},
+		"name":       {2},
+		"loc":        {3},
+		"extra":      {4},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("getStructFields() = %v, expected %v", fields, expected)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"ID":         "id",
+		"UserID":     "user_id",
+		"CreatedAt":  "created_at",
+		"HTTPServer": "http_server",
+		"name":       "name",
+	}
+	for in, expected := range tests {
+		if got := snakeCase(in); got != expected {
+			t.Errorf("snakeCase(%q) = %q, expected %q", in, got, expected)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	ctx := context.Background()
+	created := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	f.onQuery = func(query string, args []driver.NamedValue) (driver.Rows, error) {
+		if args[0].Value != int64(1) {
+			return newFakeRows("id"), nil
+		}
+		return newFakeRows("id,created_at,bio,name,loc,extra",
+			[]driver.Value{int64(1), created, "hi", "tom", "(1,2)", `{"name":"tom","age":3}`},
+		), nil
+	}
+
+	var u scanUser
+	if err := Get(ctx, db, &u, "SELECT * FROM users WHERE id = $1", 1); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if u.ID != 1 || !u.CreatedAt.Equal(created) || u.ScanProfile == nil || u.Bio != "hi" || u.UserName != "tom" {
+		t.Errorf("Get() = %+v", u)
+	}
+	if u.Location != (Point{1, 2}) {
+		t.Errorf("Get() loc = %v", u.Location)
+	}
+	if !u.Extra.Valid || u.Extra.V != (jsonbSample{Name: "tom", Age: 3}) {
+		t.Errorf("Get() extra = %+v", u.Extra)
+	}
+
+	if err := Get(ctx, db, &u, "SELECT * FROM users WHERE id = $1", 2); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Get() error = %v, expected sql.ErrNoRows", err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	ctx := context.Background()
+
+	f.onQuery = func(query string, args []driver.NamedValue) (driver.Rows, error) {
+		return newFakeRows("id,name", []driver.Value{int64(1), "tom"}, []driver.Value{int64(2), "jerry"}), nil
+	}
+
+	var users []scanUser
+	if err := Select(ctx, db, &users, "SELECT id, name FROM users"); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if len(users) != 2 || users[0].ID != 1 || users[1].UserName != "jerry" {
+		t.Errorf("Select() = %+v", users)
+	}
+
+	var ptrs []*scanUser
+	if err := Select(ctx, db, &ptrs, "SELECT id, name FROM users"); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if len(ptrs) != 2 || ptrs[0].UserName != "tom" || ptrs[1].ID != 2 {
+		t.Errorf("Select() = %+v", ptrs)
+	}
+
+	f.onQuery = func(query string, args []driver.NamedValue) (driver.Rows, error) {
+		return newFakeRows("id,unknown", []driver.Value{int64(1), "x"}), nil
+	}
+	if err := Select(ctx, db, &users, "SELECT id, unknown FROM users"); err == nil {
+		t.Error("Select() with unknown column expected error")
+	}
+
+	var notSlice scanUser
+	if err := Select(ctx, db, &notSlice, "SELECT 1"); !errors.Is(err, ErrScanDest) {
+		t.Errorf("Select() error = %v, expected ErrScanDest", err)
+	}
+}
@@ -0,0 +1,144 @@
+package qdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/kkkbird/qapp/qclock"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrTxNotSupported = errors.New("querier could not begin transaction")
+)
+
+// retry params of serialization failures and deadlocks
+var (
+	TxMaxRetries = 3
+	TxRetryDelay = 20 * time.Millisecond // doubled and jittered for each retry
+)
+
+// TxBeginner is the Querier to begin a transaction, ex *sql.DB
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// wrappedQuerier is a Querier wrapping another, ex InstrumentedQuerier, WithTx runs the transaction on
+// the inner Querier and passes the rewrapped transaction to fn
+type wrappedQuerier interface {
+	unwrap() Querier
+	rewrap(inner Querier) Querier
+}
+
+// txQuerier is the Querier passed to WithTx funcs, it tracks the savepoint depth of nested WithTx
+type txQuerier struct {
+	*sql.Tx
+	depth int
+}
+
+// WithTx run fn in a transaction of q, the transaction is committed if fn returns nil,
+// and rolled back if fn returns error or panics. serialization failures and deadlocks
+// (SQLSTATE 40001/40P01) retry the whole transaction up to TxMaxRetries times, so fn may be called more than once.
+// if q is a transaction, ex the Querier passed to fn, fn runs in a SAVEPOINT of it and opts is ignored. usage example:
+//
+//	err := qdb.WithTx(ctx, db, nil, func(q qdb.Querier) error {
+//		_, err := q.ExecContext(ctx, sqlstr)
+//		return err
+//	})
+func WithTx(ctx context.Context, q Querier, opts *sql.TxOptions, fn func(q Querier) error) error {
+	switch q := q.(type) {
+	case wrappedQuerier:
+		return WithTx(ctx, q.unwrap(), opts, func(inner Querier) error {
+			return fn(q.rewrap(inner))
+		})
+	case *txQuerier:
+		return withSavepoint(ctx, q, fn)
+	case *sql.Tx:
+		return withSavepoint(ctx, &txQuerier{Tx: q}, fn)
+	case TxBeginner:
+		return withRetry(ctx, q, opts, fn)
+	}
+	return ErrTxNotSupported
+}
+
+// IsRetryableTxError check if err is a serialization failure or deadlock
+func IsRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	return false
+}
+
+func withRetry(ctx context.Context, db TxBeginner, opts *sql.TxOptions, fn func(q Querier) error) error {
+	delay := TxRetryDelay
+
+	for attempt := 0; ; attempt++ {
+		err := runTx(ctx, db, opts, fn)
+		if err == nil || !IsRetryableTxError(err) || attempt >= TxMaxRetries {
+			return err
+		}
+
+		log.WithError(err).Debugf("retry transaction, attempt %d", attempt+1)
+
+		select {
+		case <-qclock.From(ctx).After(delay/2 + rand.N(delay/2+1)):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+func runTx(ctx context.Context, db TxBeginner, opts *sql.TxOptions, fn func(q Querier) error) (err error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(&txQuerier{Tx: tx}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			log.WithError(rbErr).Warn("rollback transaction failed")
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func withSavepoint(ctx context.Context, tx *txQuerier, fn func(q Querier) error) (err error) {
+	nested := &txQuerier{Tx: tx.Tx, depth: tx.depth + 1}
+	name := fmt.Sprintf("qdb_sp_%d", nested.depth)
+
+	if _, err = tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(r)
+		}
+	}()
+
+	if err = fn(nested); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			log.WithError(rbErr).Warn("rollback to savepoint failed")
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
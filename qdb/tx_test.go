@@ -0,0 +1,149 @@
+package qdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestWithTx(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	ctx := context.Background()
+	errFn := errors.New("fn failed")
+
+	tests := []struct {
+		name     string
+		fn       func(q Querier) error
+		wantErr  error
+		expected []string
+	}{
+		{
+			name: "Commit",
+			fn: func(q Querier) error {
+				_, err := q.ExecContext(ctx, "INSERT 1")
+				return err
+			},
+			expected: []string{"BEGIN", "INSERT 1", "COMMIT"},
+		},
+		{
+			name:     "Rollback on error",
+			fn:       func(q Querier) error { return errFn },
+			wantErr:  errFn,
+			expected: []string{"BEGIN", "ROLLBACK"},
+		},
+		{
+			name: "Nested savepoints",
+			fn: func(q Querier) error {
+				q.ExecContext(ctx, "INSERT 1")
+				err := WithTx(ctx, q, nil, func(q Querier) error {
+					q.ExecContext(ctx, "INSERT 2")
+					return WithTx(ctx, q, nil, func(q Querier) error {
+						return errFn
+					})
+				})
+				if !errors.Is(err, errFn) {
+					return err
+				}
+				return WithTx(ctx, q, nil, func(q Querier) error {
+					_, err := q.ExecContext(ctx, "INSERT 3")
+					return err
+				})
+			},
+			expected: []string{
+				"BEGIN", "INSERT 1",
+				"SAVEPOINT qdb_sp_1", "INSERT 2",
+				"SAVEPOINT qdb_sp_2", "ROLLBACK TO SAVEPOINT qdb_sp_2",
+				"ROLLBACK TO SAVEPOINT qdb_sp_1",
+				"SAVEPOINT qdb_sp_1", "INSERT 3", "RELEASE SAVEPOINT qdb_sp_1",
+				"COMMIT",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := WithTx(ctx, db, nil, tt.fn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Err mismatch: got %v, want %v", err, tt.wantErr)
+			}
+			if got := f.statements(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Statements mismatch:\n got %q\nwant %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithTxRetry(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	ctx := context.Background()
+
+	defer func(d time.Duration) { TxRetryDelay = d }(TxRetryDelay)
+	TxRetryDelay = 0
+	failures := 2
+	f.onExec = func(query string, args []driver.NamedValue) (driver.Result, error) {
+		if query == "UPDATE" && failures > 0 {
+			failures--
+			return nil, &pq.Error{Code: "40001"}
+		}
+		return driver.RowsAffected(1), nil
+	}
+
+	calls := 0
+	err := WithTx(ctx, db, nil, func(q Querier) error {
+		calls++
+		return WithTx(ctx, q, nil, func(q Querier) error { // retry from the top level
+			_, err := q.ExecContext(ctx, "UPDATE")
+			return err
+		})
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Retry mismatch: err %v, calls %d", err, calls)
+	}
+
+	// deadlock is retried until TxMaxRetries
+	f.onExec = func(query string, args []driver.NamedValue) (driver.Result, error) {
+		if query == "UPDATE" {
+			return nil, &pq.Error{Code: "40P01"}
+		}
+		return driver.RowsAffected(1), nil
+	}
+	calls = 0
+	err = WithTx(ctx, db, nil, func(q Querier) error {
+		calls++
+		_, err := q.ExecContext(ctx, "UPDATE")
+		return err
+	})
+	if !IsRetryableTxError(err) || calls != TxMaxRetries+1 {
+		t.Errorf("Retry mismatch: err %v, calls %d", err, calls)
+	}
+}
+
+func TestWithTxPanic(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("Panic mismatch: %v", r)
+		}
+		expected := []string{"BEGIN", "SAVEPOINT qdb_sp_1", "ROLLBACK TO SAVEPOINT qdb_sp_1", "ROLLBACK"}
+		if got := f.statements(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Statements mismatch:\n got %q\nwant %q", got, expected)
+		}
+	}()
+
+	WithTx(context.Background(), db, nil, func(q Querier) error {
+		return WithTx(context.Background(), q, nil, func(q Querier) error {
+			panic("boom")
+		})
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"expvar"
 	"html/template"
 	"net/http"
+	"sync"
 
 	"github.com/kkkbird/qapp/qhttp"
 
@@ -33,9 +34,26 @@ var indexHTML = `
 		<li>{{$name}} : {{$value}}</li>
 		{{end}}
 	</ul>
+	<ul>
+		{{range .Infos}}
+		<li>{{.Name}} : {{.Value}}</li>
+		{{end}}
+	</ul>
+	{{range .Lists}}
+	<h3>{{.Name}}</h3>
+	<ol>
+		{{range .Items}}
+		<li>{{.}}</li>
+		{{else}}
+		<li>none</li>
+		{{end}}
+	</ol>
+	{{end}}
 	<ul>
 		<li><a href="{{.Prefix}}pprof">pprof</a></li>
 		<li><a href="{{.Prefix}}vars">vars</a></li>
+		<li><a href="{{.Prefix}}config">config</a></li>
+		<li><a href="{{.Prefix}}panics">panics</a></li>
 	</ul>
 </html>
 `
@@ -43,14 +61,69 @@ var indexHTML = `
 func debugIndex(w http.ResponseWriter, r *http.Request) {
 	t := template.Must(template.New("index").Parse(indexHTML))
 
+	infos := make([]map[string]interface{}, 0, len(indexInfos))
+	for _, info := range indexInfos {
+		infos = append(infos, map[string]interface{}{"Name": info.name, "Value": info.getter()})
+	}
+
+	lists := make([]map[string]interface{}, 0, len(indexLists))
+	for _, list := range indexLists {
+		lists = append(lists, map[string]interface{}{"Name": list.name, "Items": list.getter()})
+	}
+
 	t.Execute(w, map[string]interface{}{
 		"Prefix":   r.URL.Path,
 		"Versions": versions,
+		"Infos":    infos,
+		"Lists":    lists,
 	})
 }
 
+type indexInfo struct {
+	name   string
+	getter func() interface{}
+}
+
+var indexInfos []indexInfo
+
+// AddIndexInfo add an info shown in the index page, getter is called on each request
+func AddIndexInfo(name string, getter func() interface{}) {
+	indexInfos = append(indexInfos, indexInfo{name, getter})
+}
+
+type indexList struct {
+	name   string
+	getter func() []string
+}
+
+var indexLists []indexList
+
+// AddIndexList add a list shown in the index page, ex top slow queries, getter is called on each request
+func AddIndexList(name string, getter func() []string) {
+	indexLists = append(indexLists, indexList{name, getter})
+}
+
+var (
+	paramsMu sync.Mutex
+	params   = make(map[string]func() interface{})
+)
+
+// AddParam publish a var in vars page, getter is called on each request. adding an existing name
+// replaces its getter, ex a database reopened by a later init
 func AddParam(name string, getter func() interface{}) {
-	expvar.Publish(name, expvar.Func(getter))
+	paramsMu.Lock()
+	defer paramsMu.Unlock()
+
+	// expvar.Publish panics on a published name, a name published by expvar directly is kept
+	if _, ok := params[name]; !ok && expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() interface{} {
+			paramsMu.Lock()
+			g := params[name]
+			paramsMu.Unlock()
+			return g()
+		}))
+	}
+	params[name] = getter
 }
 
 func RegisteDebugServerPFlags() error {
@@ -0,0 +1,49 @@
+package qdebugserver
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+
+	"github.com/kkkbird/qapp/qpanic"
+)
+
+var panicsHTML = `
+<html>
+	<h1>recent panics ({{.Total}} total)</h1>
+	{{range .Panics}}
+	<h3>{{.Value}}</h3>
+	<ul>
+		<li>signature : {{.Signature}}</li>
+		<li>context : {{.Context}}, {{.Goroutine}}</li>
+		<li>count : {{.Count}}</li>
+		<li>first seen : {{.FirstSeen.Format "2006-01-02 15:04:05"}}</li>
+		<li>last seen : {{.LastSeen.Format "2006-01-02 15:04:05"}}</li>
+	</ul>
+	<pre>{{.Stack}}</pre>
+	{{else}}
+	<p>no panic</p>
+	{{end}}
+</html>
+`
+
+// panicsHandler show recent panics, use ?format=json for json output
+func panicsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(qpanic.List())
+		return
+	}
+
+	t := template.Must(template.New("panics").Parse(panicsHTML))
+
+	t.Execute(w, map[string]interface{}{
+		"Total":  qpanic.Count(),
+		"Panics": qpanic.List(),
+	})
+}
+
+func init() {
+	AddParam("panics", func() interface{} { return qpanic.Count() })
+	AddIndexInfo("Panics", func() interface{} { return qpanic.Count() })
+}
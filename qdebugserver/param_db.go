@@ -18,20 +18,26 @@ type SqlDBStats struct {
 }
 
 func getSqlDBStatsReflect(db *sql.DB) SqlDBStats {
-
-	v := reflect.ValueOf(*db)
-
-	openConns := v.FieldByName("numOpen").Int()
-	freeConns := v.FieldByName("freeConn").Len()
-	maxIdle := v.FieldByName("maxIdle").Int()
-	maxOpen := v.FieldByName("maxOpen").Int()
+	stats := db.Stats()
+
+	// max idle is not in sql.DBStats, read by pointer to avoid copying the locks of db,
+	// zero means the default 2 and negative means 0
+	maxIdle := 0
+	if f := reflect.ValueOf(db).Elem().FieldByName("maxIdleCount"); f.IsValid() {
+		switch maxIdle = int(f.Int()); {
+		case maxIdle == 0:
+			maxIdle = 2
+		case maxIdle < 0:
+			maxIdle = 0
+		}
+	}
 
 	return SqlDBStats{
-		OpenConnections: int(openConns),
-		FreeConnections: freeConns,
+		OpenConnections: stats.OpenConnections,
+		FreeConnections: stats.Idle,
 		MaxIdle:         int(maxIdle),
-		MaxOpen:         int(maxOpen),
-		UsedConnections: int(openConns) - freeConns,
+		MaxOpen:         stats.MaxOpenConnections,
+		UsedConnections: stats.InUse,
 	}
 }
 
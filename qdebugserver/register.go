@@ -26,6 +26,8 @@ func RegisterHTTPMux(mux *http.ServeMux, prefixOptions ...string) *http.ServeMux
 	mux.HandleFunc(prefix+"/healthz", healthHandler)
 	mux.HandleFunc(prefix+"/readyz", readyzHandler)
 	mux.HandleFunc(prefix+"/version", versionHandler)
+	mux.HandleFunc(prefix+"/config", configHandler)
+	mux.HandleFunc(prefix+"/panics", panicsHandler)
 
 	return mux
 }
@@ -74,6 +76,8 @@ func RegisterGin(r *gin.Engine, prefixOptions ...string) *gin.RouterGroup {
 		debugGroup.GET("/healthz", pprofHandler(healthHandler))
 		debugGroup.GET("/readyz", pprofHandler(readyzHandler))
 		debugGroup.GET("/version", pprofHandler(versionHandler))
+		debugGroup.GET("/config", pprofHandler(configHandler))
+		debugGroup.GET("/panics", pprofHandler(panicsHandler))
 	}
 	return debugGroup
 }
@@ -88,6 +92,8 @@ func pprofHandler(h http.HandlerFunc) gin.HandlerFunc {
 var (
 	userReadyzHandler http.HandlerFunc
 	versions          map[string]string
+	versionFields     = make(map[string]func() interface{})
+	configProvider    func() interface{}
 )
 
 // SetUserReadyzHandler set user specified readyz handler
@@ -100,6 +106,16 @@ func SetVersionInfo(ver map[string]string) {
 	versions = ver
 }
 
+// SetConfigProvider set the func return app effective config, secret values should be masked by provider
+func SetConfigProvider(provider func() interface{}) {
+	configProvider = provider
+}
+
+// AddVersionField add a field to /version besides the version info, getter is called on each request
+func AddVersionField(name string, getter func() interface{}) {
+	versionFields[name] = getter
+}
+
 func readyzHandler(w http.ResponseWriter, r *http.Request) {
 	if userReadyzHandler != nil {
 		userReadyzHandler(w, r)
@@ -113,12 +129,38 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func versionHandler(w http.ResponseWriter, r *http.Request) {
-	s, err := json.Marshal(versions)
+	info := make(map[string]interface{}, len(versions)+len(versionFields))
+	for k, v := range versions {
+		info[k] = v
+	}
+	for k, getter := range versionFields {
+		info[k] = getter()
+	}
+
+	s, err := json.Marshal(info)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "marshal error:")
+		io.WriteString(w, err.Error())
+		return
+	}
+	w.Write(s)
+}
+
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	if configProvider == nil {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "config not available")
+		return
+	}
+
+	s, err := json.Marshal(configProvider())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		io.WriteString(w, "marshal error:")
 		io.WriteString(w, err.Error())
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.Write(s)
 }
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"runtime/debug"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kkkbird/qapp/qpanic"
 )
 
 // Context is the request context, can use *gin.Context directly
@@ -65,6 +67,7 @@ func GinAPIHandler(r APIRequest, middlewares ...APIMiddleware) gin.HandlerFunc {
 
 		defer func() {
 			if err := recover(); err != nil {
+				qpanic.Add(err, string(debug.Stack()), "api "+c.Request.Method+" "+c.FullPath())
 				rsp = req.RspInternalError(c, fmt.Errorf("panic: %v", err))
 			}
 		}()
@@ -15,6 +15,8 @@ import (
 
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-redis/redis_rate/v10"
+	"github.com/kkkbird/qapp/qclock"
+	"github.com/kkkbird/qapp/qcontext"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -84,13 +86,11 @@ func NewLimit(rdb *redis.Client, key string, rate int, period time.Duration, bur
 
 func WithLimit(l *Limit) func(*http.Request) error {
 	return func(req *http.Request) error {
-		var ctx context.Context
+		var ctx = req.Context() // the request deadline also limit the block time, and its qclock times the block
 
-		if l.Block <= 0 {
-			ctx = context.Background()
-		} else {
+		if l.Block > 0 {
 			var cancel context.CancelFunc
-			ctx, cancel = context.WithTimeout(context.Background(), l.Block)
+			ctx, cancel = qclock.WithTimeout(ctx, l.Block)
 			defer cancel()
 		}
 
@@ -117,17 +117,19 @@ func WithLimit(l *Limit) func(*http.Request) error {
 			}
 
 			select {
-			case <-time.After(rlt.RetryAfter):
+			case <-qclock.From(ctx).After(rlt.RetryAfter):
 
 			case <-ctx.Done():
-				return context.DeadlineExceeded
+				return context.Cause(ctx)
 			}
 		}
 	}
 }
 
-// Get method
+// Get method, the time is recorded to the qcontext.Budget of ctx as "http"
 func Get(ctx context.Context, uri string, reqOpts ...func(*http.Request) error) (resp *http.Response, err error) {
+	defer qcontext.Track(ctx, "http")()
+
 	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
 	if err != nil {
 		return nil, err
@@ -171,8 +173,10 @@ func GetJSON(ctx context.Context, uri string, result interface{}, reqOpts ...fun
 	return rsp, err
 }
 
-// Post method
+// Post method, the time is recorded to the qcontext.Budget of ctx as "http"
 func Post(ctx context.Context, uri, contentType string, body io.Reader, reqOpts ...func(*http.Request) error) (resp *http.Response, err error) {
+	defer qcontext.Track(ctx, "http")()
+
 	req, err := http.NewRequestWithContext(ctx, "POST", uri, body)
 	if err != nil {
 		return nil, err
@@ -195,8 +199,10 @@ func PostForm(ctx context.Context, uri string, data url.Values, reqOpts ...func(
 	return Post(ctx, uri, binding.MIMEPOSTForm, strings.NewReader(data.Encode()), reqOpts...)
 }
 
-// Head method
+// Head method, the time is recorded to the qcontext.Budget of ctx as "http"
 func Head(ctx context.Context, uri string, reqOpts ...func(*http.Request) error) (resp *http.Response, err error) {
+	defer qcontext.Track(ctx, "http")()
+
 	req, err := http.NewRequestWithContext(ctx, "HEAD", uri, nil)
 	if err != nil {
 		return nil, err
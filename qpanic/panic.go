@@ -0,0 +1,144 @@
+// Package qpanic keeps recent recovered panics in memory, panics are deduplicated by stack signature
+package qpanic
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is the default max number of distinct panics kept
+const DefaultCapacity = 32
+
+// Record is a distinct panic
+type Record struct {
+	Signature string    `json:"signature"`
+	Value     string    `json:"value"`   // panic value of the last occurrence
+	Context   string    `json:"context"` // where the panic is recovered, ex "daemon main.run"
+	Goroutine string    `json:"goroutine"`
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Stack     string    `json:"stack"` // sample stack of the first occurrence
+}
+
+var (
+	mu       sync.Mutex
+	capacity = DefaultCapacity
+	records  = make(map[string]*Record)
+	total    int64
+
+	addrRe = regexp.MustCompile(`\+?0x[0-9a-fA-F]+`)
+)
+
+// SetCapacity set max number of distinct panics kept, least recently seen panics are dropped
+func SetCapacity(n int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	capacity = n
+	evict()
+}
+
+// signature hash the stack without goroutine ids and addresses, so same panics have same signature
+func signature(stack string) string {
+	h := sha1.New()
+	for _, line := range strings.Split(stack, "\n") {
+		if strings.HasPrefix(line, "goroutine ") {
+			continue
+		}
+		h.Write(addrRe.ReplaceAll([]byte(line), nil))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// goroutine return the header of current goroutine, ex "goroutine 23 [running]"
+func goroutine() string {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	if i := bytes.IndexByte(buf, ':'); i > 0 {
+		buf = buf[:i]
+	}
+	return string(buf)
+}
+
+// Add record a recovered panic, it should be called in the goroutine recovering the panic
+func Add(r interface{}, stack string, context string) {
+	now := time.Now()
+	sig := signature(stack)
+	g := goroutine()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	total++
+
+	rec, ok := records[sig]
+	if !ok {
+		rec = &Record{
+			Signature: sig,
+			FirstSeen: now,
+			Stack:     stack,
+		}
+		records[sig] = rec
+	}
+
+	rec.Value = fmt.Sprint(r)
+	rec.Context = context
+	rec.Goroutine = g
+	rec.Count++
+	rec.LastSeen = now
+
+	evict()
+}
+
+func evict() {
+	for len(records) > capacity && len(records) > 0 {
+		var oldest *Record
+		for _, rec := range records {
+			if oldest == nil || rec.LastSeen.Before(oldest.LastSeen) {
+				oldest = rec
+			}
+		}
+		delete(records, oldest.Signature)
+	}
+}
+
+// List return copies of kept panics, the most recently seen first
+func List() []Record {
+	mu.Lock()
+	defer mu.Unlock()
+
+	list := make([]Record, 0, len(records))
+	for _, rec := range records {
+		list = append(list, *rec)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].LastSeen.After(list[j].LastSeen) })
+	return list
+}
+
+// Count return total number of panics recorded
+func Count() int64 {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return total
+}
+
+// Reset drop all kept panics and reset the counter
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	records = make(map[string]*Record)
+	total = 0
+}
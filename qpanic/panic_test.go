@@ -0,0 +1,60 @@
+package qpanic
+
+import (
+	"fmt"
+	"runtime/debug"
+	"testing"
+)
+
+func recoverAndAdd(context string, f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			Add(r, string(debug.Stack()), context)
+		}
+	}()
+	f()
+}
+
+func panicA(i int) { panic(fmt.Sprintf("a%d", i)) }
+func panicB()      { panic("b") }
+
+func TestAdd(t *testing.T) {
+	Reset()
+	SetCapacity(2)
+	defer SetCapacity(DefaultCapacity)
+
+	for i := 0; i < 3; i++ {
+		recoverAndAdd("test a", func() { panicA(i) })
+	}
+
+	tests := []struct {
+		name      string
+		list      func() []Record
+		wantLen   int
+		wantFirst string
+		wantCount int64
+	}{
+		{"Deduplicated", List, 1, "a2", 3},
+		{"Distinct", func() []Record { recoverAndAdd("test b", panicB); return List() }, 2, "b", 1},
+		{"Evicted", func() []Record {
+			recoverAndAdd("test c", func() { panic("c") })
+			return List()
+		}, 2, "c", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := tt.list()
+			if len(list) != tt.wantLen {
+				t.Fatalf("len mismatch: got %d, want %d", len(list), tt.wantLen)
+			}
+			if list[0].Value != tt.wantFirst || list[0].Count != tt.wantCount {
+				t.Errorf("first mismatch: got %s/%d, want %s/%d", list[0].Value, list[0].Count, tt.wantFirst, tt.wantCount)
+			}
+		})
+	}
+
+	if Count() != 5 {
+		t.Errorf("count mismatch: got %d, want 5", Count())
+	}
+}
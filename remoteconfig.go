@@ -0,0 +1,242 @@
+package qapp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kkkbird/qapp/qhttp"
+	"github.com/spf13/viper"
+)
+
+// RemoteConfig poll config from a http(s) endpoint, the remote config is merged above the config file
+type RemoteConfig struct {
+	URL        string
+	ConfigType string        // payload format, ex "yaml" or "json", detect from Content-Type or url ext if empty
+	Interval   time.Duration // poll interval, default 30s
+	CacheFile  string        // last good payload is saved to it and used if server is unreachable at start, optional
+	ReqOpts    []func(*http.Request) error
+
+	mu   sync.RWMutex
+	etag string
+	v    *viper.Viper
+}
+
+// WithRemoteConfig set remote config source of app
+func WithRemoteConfig(rc *RemoteConfig) AppOpts {
+	return func(a *Application) {
+		a.remoteConfig = rc
+	}
+}
+
+func (rc *RemoteConfig) configType(contentType string) string {
+	if len(rc.ConfigType) > 0 {
+		return rc.ConfigType
+	}
+
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch {
+		case strings.HasSuffix(mt, "json"):
+			return "json"
+		case strings.HasSuffix(mt, "yaml"):
+			return "yaml"
+		case strings.HasSuffix(mt, "toml"):
+			return "toml"
+		}
+	}
+
+	if ext := strings.TrimPrefix(path.Ext(rc.URL), "."); len(ext) > 0 {
+		return ext
+	}
+	return "yaml"
+}
+
+func (rc *RemoteConfig) parse(data []byte, configType string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigType(configType)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// fetch get config from remote, changed is false if server response 304
+func (rc *RemoteConfig) fetch(ctx context.Context) (changed bool, err error) {
+	rc.mu.RLock()
+	etag := rc.etag
+	rc.mu.RUnlock()
+
+	reqOpts := append([]func(*http.Request) error{func(req *http.Request) error {
+		if len(etag) > 0 {
+			req.Header.Set("If-None-Match", etag)
+		}
+		return nil
+	}}, rc.ReqOpts...)
+
+	rsp, err := qhttp.Get(ctx, rc.URL, reqOpts...)
+	if err != nil {
+		return false, err
+	}
+	defer rsp.Body.Close()
+
+	switch rsp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("remote config status: %d", rsp.StatusCode)
+	}
+
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return false, err
+	}
+
+	configType := rc.configType(rsp.Header.Get("Content-Type"))
+	v, err := rc.parse(data, configType)
+	if err != nil {
+		return false, err
+	}
+
+	rc.mu.Lock()
+	rc.etag = rsp.Header.Get("ETag")
+	rc.v = v
+	rc.mu.Unlock()
+
+	if len(rc.CacheFile) > 0 {
+		if err := rc.saveCache(data, configType); err != nil {
+			log.WithError(err).Warn("Save remote config cache fail")
+		}
+	}
+
+	return true, nil
+}
+
+// saveCache write the payload to CacheFile, the first line is the config type
+func (rc *RemoteConfig) saveCache(data []byte, configType string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(rc.CacheFile), filepath.Base(rc.CacheFile)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = fmt.Fprintln(tmp, configType); err == nil {
+		_, err = tmp.Write(data)
+	}
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), rc.CacheFile)
+}
+
+func (rc *RemoteConfig) loadCache() error {
+	data, err := os.ReadFile(rc.CacheFile)
+	if err != nil {
+		return err
+	}
+
+	configType, data, _ := bytes.Cut(data, []byte("\n"))
+
+	v, err := rc.parse(data, string(configType))
+	if err != nil {
+		return err
+	}
+
+	rc.mu.Lock()
+	rc.v = v
+	rc.mu.Unlock()
+	return nil
+}
+
+// load fetch the remote config at start, fallback to cache file if fetch fail
+func (rc *RemoteConfig) load(ctx context.Context) error {
+	_, err := rc.fetch(ctx)
+	if err == nil {
+		return nil
+	}
+
+	if len(rc.CacheFile) == 0 {
+		return err
+	}
+
+	log.WithError(err).Warnf("Fetch remote config fail, use cache %s", rc.CacheFile)
+	return rc.loadCache()
+}
+
+// settings return the last good remote config, nil if not loaded
+func (rc *RemoteConfig) settings() map[string]interface{} {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	if rc.v == nil {
+		return nil
+	}
+	return rc.v.AllSettings()
+}
+
+// inConfig check if key is supplied by remote config
+func (rc *RemoteConfig) inConfig(key string) bool {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	return rc.v != nil && rc.v.InConfig(key)
+}
+
+// mergeRemoteConfig merge remote config above the config file, it should be called after the config file is (re)read
+func (a *Application) mergeRemoteConfig() error {
+	if a.remoteConfig == nil {
+		return nil
+	}
+
+	if s := a.remoteConfig.settings(); s != nil {
+		return viper.MergeConfigMap(s)
+	}
+	return nil
+}
+
+// pollRemoteConfig is a daemon polling remote config, it reload config and notify app if remote config changed
+func (a *Application) pollRemoteConfig(ctx context.Context) error {
+	rc := a.remoteConfig
+
+	interval := rc.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		changed, err := rc.fetch(ctx)
+		if err != nil {
+			log.WithError(err).Warn("Poll remote config fail")
+			continue
+		}
+
+		if !changed {
+			continue
+		}
+
+		log.Trace("Remote config changed:", rc.URL)
+		a.reloadConfig()
+	}
+}
@@ -0,0 +1,175 @@
+package qapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func newRemoteConfigServer(payload *atomic.Value, hits *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+
+		body := payload.Load().(string)
+		etag := `"` + body + `"`
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+}
+
+func TestRemoteConfig_FetchWithETag(t *testing.T) {
+	var payload atomic.Value
+	var hits atomic.Int32
+	payload.Store(`{"addr":":8012","db":{"name":"a"}}`)
+
+	srv := newRemoteConfigServer(&payload, &hits)
+	defer srv.Close()
+
+	rc := &RemoteConfig{URL: srv.URL}
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		payload     string
+		wantChanged bool
+		wantName    string
+	}{
+		{"First fetch", `{"addr":":8012","db":{"name":"a"}}`, true, "a"},
+		{"Not modified", `{"addr":":8012","db":{"name":"a"}}`, false, "a"},
+		{"Modified", `{"addr":":8012","db":{"name":"b"}}`, true, "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload.Store(tt.payload)
+
+			changed, err := rc.fetch(ctx)
+			if err != nil {
+				t.Fatalf("fetch error: %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed mismatch: got %v, want %v", changed, tt.wantChanged)
+			}
+			if !rc.inConfig("db.name") {
+				t.Errorf("db.name should be in remote config")
+			}
+			if got := rc.settings()["db"].(map[string]interface{})["name"]; got != tt.wantName {
+				t.Errorf("db.name mismatch: got %v, want %v", got, tt.wantName)
+			}
+		})
+	}
+
+	if hits.Load() != int32(len(tests)) {
+		t.Errorf("hits mismatch: got %d, want %d", hits.Load(), len(tests))
+	}
+}
+
+func TestRemoteConfig_CacheFallback(t *testing.T) {
+	var payload atomic.Value
+	var hits atomic.Int32
+	payload.Store(`{"token":"abc"}`)
+
+	srv := newRemoteConfigServer(&payload, &hits)
+
+	cacheFile := filepath.Join(t.TempDir(), "remote.cache")
+
+	rc := &RemoteConfig{URL: srv.URL, CacheFile: cacheFile}
+	if err := rc.load(context.Background()); err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+
+	srv.Close()
+
+	// cold start with server unreachable
+	rc2 := &RemoteConfig{URL: srv.URL, CacheFile: cacheFile}
+	if err := rc2.load(context.Background()); err != nil {
+		t.Fatalf("load from cache error: %v", err)
+	}
+	if got := rc2.settings()["token"]; got != "abc" {
+		t.Errorf("token mismatch: got %v, want abc", got)
+	}
+
+	// no cache
+	rc3 := &RemoteConfig{URL: srv.URL}
+	if err := rc3.load(context.Background()); err == nil {
+		t.Errorf("load should fail without cache")
+	}
+}
+
+func TestPollRemoteConfigReloadRace(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	file := filepath.Join(t.TempDir(), "app.yml")
+	if err := os.WriteFile(file, []byte("app:\n  name: from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetConfigFile(file)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload atomic.Value
+	var hits atomic.Int32
+	payload.Store(`{"db":{"name":"0"}}`)
+
+	srv := newRemoteConfigServer(&payload, &hits)
+	defer srv.Close()
+
+	var (
+		reloads atomic.Int32
+		dbName  atomic.Value
+	)
+	a := &Application{
+		cmdline:          pflag.NewFlagSet("test", pflag.ContinueOnError),
+		configFileLoaded: true,
+		remoteConfig:     &RemoteConfig{URL: srv.URL, Interval: time.Millisecond},
+		onConfigFileChanged: func() { // called with reload lock held, it is safe to read viper
+			reloads.Add(1)
+			dbName.Store(viper.GetString("db.name"))
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- a.pollRemoteConfig(ctx) }()
+
+	// reload by config file watch concurrently with remote config changes
+	for i := 1; i <= 20; i++ {
+		payload.Store(fmt.Sprintf(`{"db":{"name":"%d"}}`, i))
+		a.reloadConfig()
+		time.Sleep(time.Millisecond)
+	}
+	for deadline := time.Now().Add(5 * time.Second); dbName.Load() != "20"; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("remote config change is not reloaded")
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("pollRemoteConfig() error = %v", err)
+	}
+
+	if got := viper.GetString("app.name"); got != "from-file" {
+		t.Errorf("app.name = %q, want from-file", got)
+	}
+	if reloads.Load() <= 20 {
+		t.Errorf("reloads = %d, remote changes should reload config", reloads.Load())
+	}
+}
@@ -0,0 +1,213 @@
+package qapp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const semverOps = "=!<>~^"
+
+// semver is a parsed semantic version, missing minor or patch is marked as wildcard in constraints
+type semver struct {
+	parts      [3]int
+	wildcard   int // index of first wildcard part, 3 means none
+	prerelease []string
+}
+
+func parseSemver(version string) (semver, error) {
+	var v semver
+
+	s := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	s, _, _ = strings.Cut(s, "+") // ignore build metadata
+
+	s, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		v.prerelease = strings.Split(pre, ".")
+	}
+
+	nums := strings.Split(s, ".")
+	if len(nums) > 3 || len(nums[0]) == 0 {
+		return v, fmt.Errorf("invalid version %q", version)
+	}
+
+	v.wildcard = 3
+	for i := 0; i < 3; i++ {
+		if i >= len(nums) || nums[i] == "x" || nums[i] == "X" || nums[i] == "*" {
+			v.wildcard = i
+			break
+		}
+
+		n, err := strconv.Atoi(nums[i])
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", version)
+		}
+		v.parts[i] = n
+	}
+
+	return v, nil
+}
+
+// compare return -1, 0 or 1, prerelease is compared by semver precedence
+func (v semver) compare(o semver) int {
+	for i := 0; i < 3; i++ {
+		if v.parts[i] != o.parts[i] {
+			if v.parts[i] < o.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		a, b := v.prerelease[i], o.prerelease[i]
+		if a == b {
+			continue
+		}
+
+		na, aErr := strconv.Atoi(a)
+		nb, bErr := strconv.Atoi(b)
+		switch {
+		case aErr == nil && bErr == nil:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case aErr == nil: // numeric identifiers have lower precedence
+			return -1
+		case bErr == nil:
+			return 1
+		case a < b:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	switch {
+	case len(v.prerelease) < len(o.prerelease):
+		return -1
+	case len(v.prerelease) > len(o.prerelease):
+		return 1
+	}
+	return 0
+}
+
+// bump return the upper bound of the wildcard or caret/tilde range, increasing part idx
+func (v semver) bump(idx int) semver {
+	u := semver{wildcard: 3}
+	copy(u.parts[:idx], v.parts[:idx])
+	u.parts[idx] = v.parts[idx] + 1
+	u.prerelease = []string{"0"} // lowest prerelease, so prerelease of upper bound is excluded
+	return u
+}
+
+// matchOne check a single constraint like ">=1.2", "^1.2.3", "~1.2" or "1.x"
+func (v semver) matchOne(c string) (bool, error) {
+	i := strings.IndexFunc(c, func(r rune) bool { return !strings.ContainsRune(semverOps, r) })
+	if i < 0 {
+		i = len(c)
+	}
+	op := c[:i]
+	c = strings.TrimSpace(c[i:])
+
+	cv, err := parseSemver(c)
+	if err != nil {
+		return false, err
+	}
+
+	lower := cv
+	for i := cv.wildcard; i < 3; i++ {
+		lower.parts[i] = 0
+	}
+
+	switch op {
+	case "", "=", "==":
+		if cv.wildcard == 3 {
+			return v.compare(cv) == 0, nil
+		}
+		if cv.wildcard == 0 {
+			return true, nil
+		}
+		return v.compare(lower) >= 0 && v.compare(cv.bump(cv.wildcard-1)) < 0, nil
+	case "!=":
+		ok, err := v.matchOne(c)
+		return !ok, err
+	case ">":
+		if cv.wildcard < 3 && cv.wildcard > 0 {
+			return v.compare(cv.bump(cv.wildcard-1)) >= 0, nil
+		}
+		return v.compare(lower) > 0, nil
+	case ">=":
+		return v.compare(lower) >= 0, nil
+	case "<":
+		return v.compare(lower) < 0, nil
+	case "<=":
+		if cv.wildcard < 3 && cv.wildcard > 0 {
+			return v.compare(cv.bump(cv.wildcard-1)) < 0, nil
+		}
+		return v.compare(lower) <= 0, nil
+	case "~":
+		idx := 1 // ~1.2.3 := >=1.2.3 <1.3.0, ~1 := >=1.0.0 <2.0.0
+		if cv.wildcard < 2 {
+			idx = 0
+		}
+		return v.compare(lower) >= 0 && v.compare(cv.bump(idx)) < 0, nil
+	case "^":
+		idx := 0 // ^1.2.3 := <2.0.0, ^0.2.3 := <0.3.0, ^0.0.3 := <0.0.4
+		for idx < 2 && idx+1 < cv.wildcard && cv.parts[idx] == 0 {
+			idx++
+		}
+		return v.compare(lower) >= 0 && v.compare(cv.bump(idx)) < 0, nil
+	}
+
+	return false, fmt.Errorf("invalid constraint operator %q", op)
+}
+
+// matchSemver check version against constraint, constraints separated by space or comma are AND-ed,
+// and "||" separated groups are OR-ed, ex ">=1.2.0, <2 || ^3.1"
+func matchSemver(version, constraint string) (bool, error) {
+	v, err := parseSemver(version)
+	if err != nil {
+		return false, err
+	}
+	if v.wildcard != 3 {
+		return false, fmt.Errorf("invalid version %q", version)
+	}
+
+	for _, group := range strings.Split(constraint, "||") {
+		cs := strings.FieldsFunc(group, func(r rune) bool { return r == ' ' || r == ',' })
+		if len(cs) == 0 {
+			return false, fmt.Errorf("invalid constraint %q", constraint)
+		}
+
+		matched := true
+		for i := 0; i < len(cs); i++ {
+			c := cs[i]
+			if strings.TrimLeft(c, semverOps) == "" && i+1 < len(cs) { // operator separated by space, ex ">= 1.2"
+				i++
+				c += cs[i]
+			}
+
+			ok, err := v.matchOne(c)
+			if err != nil {
+				return false, err
+			}
+			matched = matched && ok
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,46 @@
+package qapp
+
+import "testing"
+
+func TestMatchSemver(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+		wantErr    bool
+	}{
+		{"1.2.3", "1.2.3", true, false},
+		{"v1.2.3", "=v1.2.3", true, false},
+		{"1.2.3", ">=1.2.0", true, false},
+		{"1.2.3", ">= 1.2.0, <2", true, false},
+		{"2.0.0", ">=1.2.0 <2", false, false},
+		{"1.2.3", "^1.1", true, false},
+		{"2.0.0", "^1.1", false, false},
+		{"0.2.5", "^0.2.3", true, false},
+		{"0.3.0", "^0.2.3", false, false},
+		{"1.2.9", "~1.2.3", true, false},
+		{"1.3.0", "~1.2.3", false, false},
+		{"1.9.0", "1.x", true, false},
+		{"2.0.0", "1.x", false, false},
+		{"1.3.0-alpha", "<1.3", true, false},
+		{"1.3.0-alpha", "~1.2", false, false},
+		{"1.0.0-alpha", "<1.0.0-beta", true, false},
+		{"1.0.0-alpha.2", ">1.0.0-alpha.10", false, false},
+		{"3.1.4", "<2 || ^3.1", true, false},
+		{"1.2.3", "!=1.2.3", false, false},
+		{"unknown-version", ">=1", false, true},
+		{"1.2.3", "=>1", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version+" "+tt.constraint, func(t *testing.T) {
+			got, err := matchSemver(tt.version, tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err mismatch: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
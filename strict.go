@@ -0,0 +1,165 @@
+package qapp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var registeredPrefixes = []string{"logger"} // logger.* is read by qlog
+
+// RegisterConfigPrefix register a config prefix, all keys under it are known in strict mode,
+// it is used by modules read a whole sub tree of config
+func RegisterConfigPrefix(prefix string) {
+	registeredKeysMu.Lock()
+	defer registeredKeysMu.Unlock()
+
+	registeredPrefixes = append(registeredPrefixes, strings.ToLower(prefix))
+}
+
+// WithStrictMode make app fail to start on unknown flags, unknown config keys
+// and unknown env vars with the env prefix
+func WithStrictMode() AppOpts {
+	return func(a *Application) {
+		a.strict = true
+	}
+}
+
+// knownKeys return all flags and registered keys
+func (a *Application) knownKeys() []string {
+	infos := a.configKeys()
+
+	keys := make([]string, 0, len(infos))
+	for _, info := range infos {
+		keys = append(keys, info.Key)
+	}
+	return keys
+}
+
+func (a *Application) isKnownKey(key string, known []string) bool {
+	for _, k := range known {
+		if k == key {
+			return true
+		}
+	}
+
+	registeredKeysMu.Lock()
+	defer registeredKeysMu.Unlock()
+
+	for _, p := range registeredPrefixes {
+		if key == p || strings.HasPrefix(key, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStrict check unknown config keys and envs
+func (a *Application) checkStrict() error {
+	var errs []error
+
+	known := a.knownKeys()
+
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !viper.InConfig(k) || a.isKnownKey(k, known) {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("unknown config key %q%s", k, didYouMean(k, known)))
+	}
+
+	if len(a.envPrefix) > 0 {
+		envPrefix := strings.ToUpper(a.envPrefix) + "_"
+
+		knownEnvs := make([]string, 0, len(known))
+		for _, k := range known {
+			knownEnvs = append(knownEnvs, a.envName(k))
+		}
+
+		var prefixEnvs []string
+		registeredKeysMu.Lock()
+		for _, p := range registeredPrefixes {
+			prefixEnvs = append(prefixEnvs, a.envName(p))
+		}
+		registeredKeysMu.Unlock()
+
+		envs := os.Environ()
+		sort.Strings(envs)
+	__env_loop:
+		for _, env := range envs {
+			name, _, _ := strings.Cut(env, "=")
+			if !strings.HasPrefix(name, envPrefix) {
+				continue
+			}
+
+			for _, e := range knownEnvs {
+				if name == e {
+					continue __env_loop
+				}
+			}
+			for _, e := range prefixEnvs {
+				if name == e || strings.HasPrefix(name, e+"_") {
+					continue __env_loop
+				}
+			}
+			errs = append(errs, fmt.Errorf("unknown env %q%s", name, didYouMean(name, knownEnvs)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// strictParseError add suggestion to unknown flag error
+func (a *Application) strictParseError(err error) error {
+	var nErr *pflag.NotExistError
+	if !errors.As(err, &nErr) || len(nErr.GetSpecifiedShortnames()) > 0 {
+		return err
+	}
+
+	return fmt.Errorf("%w%s", err, didYouMean(nErr.GetSpecifiedName(), a.knownKeys()))
+}
+
+// didYouMean return suggestion of the most similar candidate, or "" if nothing is similar enough
+func didYouMean(s string, candidates []string) string {
+	best, bestDist := "", len(s)/3+2
+
+	for _, c := range candidates {
+		if d := levenshtein(strings.ToLower(s), strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+
+	if len(best) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
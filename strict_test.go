@@ -0,0 +1,101 @@
+package qapp
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestDidYouMean(t *testing.T) {
+	candidates := []string{"debugserver.enabled", "debugserver.addr", "file", "QAPP_DEBUGSERVER_ADDR"}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"Missing suffix", "debugserver.enable", `, did you mean "debugserver.enabled"?`},
+		{"Typo", "debugserver.adr", `, did you mean "debugserver.addr"?`},
+		{"Env typo", "QAPP_DEBUGSERVER_ADR", `, did you mean "QAPP_DEBUGSERVER_ADDR"?`},
+		{"Nothing similar", "redis.url", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := didYouMean(tt.in, candidates); got != tt.want {
+				t.Errorf("didYouMean(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func newStrictTestApp(t *testing.T) *Application {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	RegisterConfigKey("strict.addr", ":8080", "listen address")
+
+	cmdline := pflag.NewFlagSet("test", pflag.ExitOnError)
+	cmdline.String("strict.name", "", "name")
+	cmdline.SetOutput(io.Discard)
+
+	return &Application{strict: true, envPrefix: "qstrict", cmdline: cmdline}
+}
+
+func TestCheckStrict(t *testing.T) {
+	a := newStrictTestApp(t)
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader("strict:\n  adr: \":80\"\n  name: test\nlogger:\n  level: debug\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("QSTRICT_STRICT_ADDR", ":80")
+	t.Setenv("QSTRICT_STRICT_NAM", "test")
+
+	err = a.checkStrict()
+	if err == nil {
+		t.Fatal("checkStrict() expected error")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{
+		`unknown config key "strict.adr", did you mean "strict.addr"?`,
+		`unknown env "QSTRICT_STRICT_NAM", did you mean "QSTRICT_STRICT_NAME"?`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("checkStrict() error = %q, want %q", msg, want)
+		}
+	}
+	for _, known := range []string{
+		`unknown config key "strict.name"`,
+		`unknown config key "logger.level"`,
+		`unknown env "QSTRICT_STRICT_ADDR"`,
+	} {
+		if strings.Contains(msg, known) {
+			t.Errorf("checkStrict() error = %q, should not contain %q", msg, known)
+		}
+	}
+}
+
+func TestParseFlagsStrict(t *testing.T) {
+	a := newStrictTestApp(t)
+
+	err := a.parseFlags([]string{"--strict.nam=x"})
+	if err == nil || !strings.Contains(err.Error(), `did you mean "strict.name"?`) {
+		t.Errorf("parseFlags() error = %v, want suggestion", err)
+	}
+
+	if err = a.parseFlags([]string{"--help"}); !errors.Is(err, ErrExit) {
+		t.Errorf("parseFlags(--help) error = %v, want %v", err, ErrExit)
+	}
+
+	if err = a.parseFlags([]string{"--strict.name=x"}); err != nil {
+		t.Errorf("parseFlags() error = %v", err)
+	}
+}
@@ -1,10 +1,17 @@
 package qapp
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
+	"os"
+	"runtime/debug"
+	"strings"
 	"text/template"
+	"time"
 
 	"github.com/kkkbird/qapp/qdebugserver"
+	"go.yaml.in/yaml/v3"
 )
 
 // predefined version params
@@ -15,31 +22,149 @@ var (
 	GoVersion = "unknown-goversion"
 )
 
+// BuildDep is a dependency module of the app binary
+type BuildDep struct {
+	Path    string
+	Version string
+	Sum     string `json:",omitempty"`
+	Replace string `json:",omitempty"` // replaced by path[@version]
+}
+
+var buildDeps []BuildDep
+
 var versionTemplate = `  App: {{.Name}}
   Version:      {{.Version}}
   Build time:   {{.BuildTime}}
   GitHash:      {{.GitHash}}
-  Go version:   {{.GoVersion}}`
+  Go version:   {{.GoVersion}}
+  Instance:     {{.Instance.ID}}
+  Host:         {{.Instance.Hostname}} (pid {{.Instance.PID}})
+  Start time:   {{.Instance.StartTime.Format "2006-01-02 15:04:05"}} (up {{.Instance.Uptime}})`
+
+func isUnknownVersion(s string) bool {
+	return strings.HasPrefix(s, "unknown-")
+}
+
+// fillVersionFromBuildInfo fill version params not set by ldflags from go build info
+func fillVersionFromBuildInfo(bi *debug.BuildInfo) {
+	settings := make(map[string]string)
+	for _, s := range bi.Settings {
+		settings[s.Key] = s.Value
+	}
+
+	if v := bi.Main.Version; isUnknownVersion(Version) && len(v) > 0 && v != "(devel)" {
+		Version = v
+	}
 
-func showAppVersion(w io.Writer, name string) error {
-	t := template.Must(template.New("version").Parse(versionTemplate))
+	if t, ok := settings["vcs.time"]; ok && isUnknownVersion(BuildTime) {
+		BuildTime = t
+	}
 
-	err := t.Execute(w, map[string]string{
-		"Name":      name,
+	if rev, ok := settings["vcs.revision"]; ok && isUnknownVersion(GitHash) {
+		GitHash = rev
+		if settings["vcs.modified"] == "true" {
+			GitHash += "-dirty"
+		}
+	}
+
+	if isUnknownVersion(GoVersion) && len(bi.GoVersion) > 0 {
+		GoVersion = bi.GoVersion
+	}
+
+	buildDeps = make([]BuildDep, 0, len(bi.Deps))
+	for _, d := range bi.Deps {
+		dep := BuildDep{Path: d.Path, Version: d.Version, Sum: d.Sum}
+		if d.Replace != nil {
+			dep.Replace = d.Replace.Path
+			if len(d.Replace.Version) > 0 {
+				dep.Replace += "@" + d.Replace.Version
+			}
+		}
+		buildDeps = append(buildDeps, dep)
+	}
+}
+
+// versionMap return the build version params, it is also the version info of debug server
+func versionMap() map[string]string {
+	return map[string]string{
 		"Version":   Version,
 		"BuildTime": BuildTime,
 		"GitHash":   GitHash,
 		"GoVersion": GoVersion,
-	})
+	}
+}
 
-	return err
+func versionInfo(name string) map[string]interface{} {
+	info := map[string]interface{}{
+		"Name":     name,
+		"Deps":     buildDeps,
+		"Instance": Instance(),
+	}
+	for k, v := range versionMap() {
+		info[k] = v
+	}
+	return info
+}
+
+// showAppVersion write version with format, format could be "text"(default), "json", "yaml" or "short"
+func showAppVersion(w io.Writer, name string, format string) error {
+	switch format {
+	case "", "text":
+		t := template.Must(template.New("version").Parse(versionTemplate))
+		return t.Execute(w, versionInfo(name))
+	case "short":
+		_, err := fmt.Fprintln(w, Version)
+		return err
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(versionInfo(name))
+	case "yaml":
+		// convert by json to keep the same field names as json output
+		b, err := json.Marshal(versionInfo(name))
+		if err != nil {
+			return err
+		}
+		var v interface{}
+		if err = json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		return yaml.NewEncoder(w).Encode(v)
+	}
+
+	return fmt.Errorf("unknown output format: %s", format)
+}
+
+// checkAppVersion check Version against a semver constraint, ex ">=1.2.0, <2", it returns ErrShowVersion if satisfied,
+// ErrVersionMismatch if not, and ErrVersionConstraint if the constraint is invalid
+func checkAppVersion(w io.Writer, constraint string) error {
+	ok, err := matchSemver(Version, constraint)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return ErrVersionConstraint
+	}
+
+	if !ok {
+		fmt.Fprintf(w, "version %s does not satisfy %q\n", Version, constraint)
+		return ErrVersionMismatch
+	}
+
+	fmt.Fprintf(w, "version %s satisfies %q\n", Version, constraint)
+	return ErrShowVersion
 }
 
 func init() {
-	qdebugserver.SetVersionInfo(map[string]string{
-		"Version":   Version,
-		"BuildTime": BuildTime,
-		"GitHash":   GitHash,
-		"GoVersion": GoVersion,
-	})
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		fillVersionFromBuildInfo(bi)
+	}
+
+	qdebugserver.SetVersionInfo(versionMap())
+	qdebugserver.AddVersionField("Deps", func() interface{} { return buildDeps })
+	qdebugserver.AddVersionField("Instance", func() interface{} { return Instance() })
+
+	qdebugserver.AddIndexInfo("Instance", func() interface{} { return instanceID })
+	qdebugserver.AddIndexInfo("Host", func() interface{} { return hostname })
+	qdebugserver.AddIndexInfo("PID", func() interface{} { return os.Getpid() })
+	qdebugserver.AddIndexInfo("StartTime", func() interface{} { return appStartTime.Format(time.RFC3339) })
+	qdebugserver.AddIndexInfo("Uptime", func() interface{} { return Instance().Uptime })
 }
@@ -0,0 +1,93 @@
+package qapp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"testing"
+)
+
+func TestFillVersionFromBuildInfo(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.25.0",
+		Main:      debug.Module{Path: "example.com/app", Version: "v1.2.3"},
+		Deps: []*debug.Module{
+			{Path: "example.com/dep", Version: "v0.1.0", Replace: &debug.Module{Path: "../dep", Version: ""}},
+		},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abcdef"},
+			{Key: "vcs.time", Value: "2024-01-02T03:04:05Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	tests := []struct {
+		name                                    string
+		version, buildTime, gitHash, goVer      string
+		wantVersion, wantBuildTime, wantGitHash string
+	}{
+		{
+			name:    "Fill unknown",
+			version: "unknown-version", buildTime: "unknown-buildtime", gitHash: "unknown-githash", goVer: "unknown-goversion",
+			wantVersion: "v1.2.3", wantBuildTime: "2024-01-02T03:04:05Z", wantGitHash: "abcdef-dirty",
+		},
+		{
+			name:    "Keep ldflags",
+			version: "1.0.0", buildTime: "today", gitHash: "123456", goVer: "go version go1.25.0",
+			wantVersion: "1.0.0", wantBuildTime: "today", wantGitHash: "123456",
+		},
+	}
+
+	old := []string{Version, BuildTime, GitHash, GoVersion}
+	defer func() { Version, BuildTime, GitHash, GoVersion = old[0], old[1], old[2], old[3] }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Version, BuildTime, GitHash, GoVersion = tt.version, tt.buildTime, tt.gitHash, tt.goVer
+
+			fillVersionFromBuildInfo(bi)
+
+			if Version != tt.wantVersion || BuildTime != tt.wantBuildTime || GitHash != tt.wantGitHash {
+				t.Errorf("got %s/%s/%s, want %s/%s/%s", Version, BuildTime, GitHash, tt.wantVersion, tt.wantBuildTime, tt.wantGitHash)
+			}
+			if isUnknownVersion(GoVersion) {
+				t.Errorf("GoVersion not filled")
+			}
+			if len(buildDeps) != 1 || buildDeps[0].Replace != "../dep" {
+				t.Errorf("deps mismatch: %+v", buildDeps)
+			}
+		})
+	}
+}
+
+func TestCheckAppVersion(t *testing.T) {
+	defer func(v string) { Version = v }(Version)
+	Version = "v1.2.3"
+
+	tests := []struct {
+		constraint string
+		wantErr    error
+		wantCode   int
+	}{
+		{">=1.2.0, <2", ErrShowVersion, 0},
+		{">=2", ErrVersionMismatch, 1},
+		{"=>1", ErrVersionConstraint, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			err := checkAppVersion(io.Discard, tt.constraint)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkAppVersion() error = %v, want %v", err, tt.wantErr)
+			}
+			if !isExitRequest(err) {
+				t.Errorf("isExitRequest(%v) = false", err)
+			}
+			// init errors are formatted with the func name before reaching Run
+			if code := exitCode(fmt.Errorf("initParams():%s", err)); code != tt.wantCode {
+				t.Errorf("exitCode() = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}